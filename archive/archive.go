@@ -0,0 +1,293 @@
+// Package archive exports rows of the logs table to compressed files and
+// imports them back.
+//
+// An export is a directory holding one file per UTC day and a manifest.json
+// listing the files with their time range, row count and SHA-256 checksum.
+// Files are gzip compressed NDJSON or Parquet.
+package archive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ManifestName is the name of the manifest file of an export directory.
+const ManifestName = "manifest.json"
+
+// Format is the file format of an export.
+type Format string
+
+// Supported formats.
+const (
+	// NDJSON writes one JSON object per line, gzip compressed.
+	NDJSON Format = "ndjson"
+
+	// Parquet writes columnar Parquet files (snappy compressed), readable by
+	// DuckDB, Spark, Athena, ...
+	Parquet Format = "parquet"
+)
+
+// ext returns the file extension of f.
+func (f Format) ext() (string, error) {
+	switch f {
+	case NDJSON:
+		return ".ndjson.gz", nil
+	case Parquet:
+		return ".parquet", nil
+	}
+	return "", fmt.Errorf("archive: unsupported format %q", f)
+}
+
+// Record is an exported row of the logs table.
+type Record struct {
+	ID      int64           `json:"id"`
+	TS      time.Time       `json:"ts"`
+	ReqID   string          `json:"req_id,omitempty"`
+	TraceID string          `json:"trace_id,omitempty"`
+	Raw     json.RawMessage `json:"raw"`
+}
+
+// Manifest describes an export directory.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Format    Format    `json:"format"`
+
+	// Since and Until bound the exported time range (Until excluded).
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	// MaxID is the highest id of the logs table when the export started;
+	// rows inserted afterwards are not exported.
+	MaxID int64 `json:"max_id"`
+
+	Files []File `json:"files"`
+}
+
+// File describes an exported file, holding the rows with From <= ts < To and
+// id <= Manifest.MaxID.
+type File struct {
+	Name   string    `json:"name"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Rows   int64     `json:"rows"`
+	Bytes  int64     `json:"bytes"`
+	SHA256 string    `json:"sha256"`
+}
+
+// ReadManifest reads the manifest of an export directory.
+func ReadManifest(dir string) (Manifest, error) {
+	var m Manifest
+	b, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return m, fmt.Errorf("archive: reading manifest failed: %w", err)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("archive: decoding manifest failed: %w", err)
+	}
+	if m.Version != 1 {
+		return m, fmt.Errorf("archive: unsupported manifest version %d", m.Version)
+	}
+	return m, nil
+}
+
+// writeManifest writes m to dir atomically.
+func writeManifest(dir string, m Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, ManifestName), func(w io.Writer) error {
+		_, err := w.Write(append(b, '\n'))
+		return err
+	})
+}
+
+// Verify checks the size and checksum of every file listed in the manifest of
+// dir.
+func Verify(dir string, m Manifest) error {
+	for _, f := range m.Files {
+		sum, n, err := checksum(filepath.Join(dir, f.Name))
+		if err != nil {
+			return err
+		}
+		if n != f.Bytes || sum != f.SHA256 {
+			return fmt.Errorf("archive: %s does not match its manifest checksum", f.Name)
+		}
+	}
+	return nil
+}
+
+// checksum returns the hex SHA-256 and size of a file.
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("archive: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("archive: reading %s failed: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// writeFileAtomic writes path through a temporary file renamed once written
+// and synced, so a crash never leaves a truncated file under its final name.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("archive: writing %s failed: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// recordWriter writes records in a given format.
+type recordWriter interface {
+	Write(r Record) error
+	Close() error
+}
+
+// recordReader reads records until io.EOF.
+type recordReader interface {
+	Read() (Record, error)
+	Close() error
+}
+
+// newWriter returns a writer of records in format f to w. Close flushes the
+// records but does not close w.
+func newWriter(f Format, w io.Writer) (recordWriter, error) {
+	switch f {
+	case NDJSON:
+		gz := gzip.NewWriter(w)
+		return &ndjsonWriter{gz: gz, enc: json.NewEncoder(gz)}, nil
+	case Parquet:
+		pw := parquet.NewGenericWriter[parquetRow](w,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(100_000),
+		)
+		return &parquetWriter{w: pw}, nil
+	}
+	return nil, fmt.Errorf("archive: unsupported format %q", f)
+}
+
+// newReader returns a reader of the records in format f stored in file.
+func newReader(f Format, file *os.File) (recordReader, error) {
+	switch f {
+	case NDJSON:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+		return &ndjsonReader{gz: gz, dec: json.NewDecoder(gz)}, nil
+	case Parquet:
+		return &parquetReader{r: parquet.NewGenericReader[parquetRow](file)}, nil
+	}
+	return nil, fmt.Errorf("archive: unsupported format %q", f)
+}
+
+// ndjsonWriter writes gzip compressed JSON lines.
+type ndjsonWriter struct {
+	gz  *gzip.Writer
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(r Record) error { return w.enc.Encode(r) }
+func (w *ndjsonWriter) Close() error         { return w.gz.Close() }
+
+// ndjsonReader reads gzip compressed JSON lines.
+type ndjsonReader struct {
+	gz  *gzip.Reader
+	dec *json.Decoder
+}
+
+func (r *ndjsonReader) Read() (Record, error) {
+	var rec Record
+	err := r.dec.Decode(&rec)
+	return rec, err
+}
+
+func (r *ndjsonReader) Close() error { return r.gz.Close() }
+
+// parquetRow is the Parquet schema of a record.
+type parquetRow struct {
+	ID      int64     `parquet:"id"`
+	TS      time.Time `parquet:"ts,timestamp(microsecond)"`
+	ReqID   string    `parquet:"req_id,optional"`
+	TraceID string    `parquet:"trace_id,optional"`
+	Raw     string    `parquet:"raw,json"`
+}
+
+// parquetWriter writes records as Parquet rows, buffering them by batches.
+type parquetWriter struct {
+	w   *parquet.GenericWriter[parquetRow]
+	buf []parquetRow
+}
+
+func (w *parquetWriter) Write(r Record) error {
+	w.buf = append(w.buf, parquetRow{ID: r.ID, TS: r.TS, ReqID: r.ReqID, TraceID: r.TraceID, Raw: string(r.Raw)})
+	if len(w.buf) < 1024 {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *parquetWriter) flush() error {
+	_, err := w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.w.Close()
+}
+
+// parquetReader reads Parquet rows as records.
+type parquetReader struct {
+	r   *parquet.GenericReader[parquetRow]
+	buf [1]parquetRow
+}
+
+func (r *parquetReader) Read() (Record, error) {
+	n, err := r.r.Read(r.buf[:])
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return Record{}, err
+	}
+	row := r.buf[0]
+	return Record{ID: row.ID, TS: row.TS, ReqID: row.ReqID, TraceID: row.TraceID, Raw: json.RawMessage(row.Raw)}, nil
+}
+
+func (r *parquetReader) Close() error { return r.r.Close() }
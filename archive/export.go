@@ -0,0 +1,267 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errManifestExists is returned by Export when the directory already holds
+// an export.
+var errManifestExists = errors.New("archive: directory already holds a manifest")
+
+// ExportConfig controls Export.
+type ExportConfig struct {
+	// Dir is the directory the files are written to. It is created if
+	// missing and must not hold a previous export.
+	Dir string
+
+	// Since and Until bound the exported rows by their ts column (Until
+	// excluded). Until is required; a zero Since exports everything before
+	// Until.
+	Since time.Time
+	Until time.Time
+
+	// Format of the files. If empty, NDJSON is used.
+	Format Format
+
+	// DeleteAfterExport deletes the exported rows from the logs table once
+	// every file and the manifest are written and verified. Rows are deleted
+	// one file at a time, in a transaction rolled back if the number of
+	// deleted rows differs from the number of exported rows.
+	DeleteAfterExport bool
+}
+
+// exportSQL selects the rows of a chunk. The id bound excludes rows inserted
+// after the export started, so that the exported and deleted rows match.
+const exportSQL = `
+SELECT id, ts, req_id, trace_id, raw
+FROM logs
+WHERE ts >= $1 AND ts < $2 AND id <= $3
+ORDER BY ts, id`
+
+// deleteSQL deletes the rows of a chunk.
+const deleteSQL = `DELETE FROM logs WHERE ts >= $1 AND ts < $2 AND id <= $3`
+
+// Export writes the rows of the logs table between cfg.Since and cfg.Until to
+// one file per UTC day in cfg.Dir, then writes the manifest. Days without rows
+// produce no file.
+func Export(ctx context.Context, db *sql.DB, cfg ExportConfig) (Manifest, error) {
+	m, err := export(ctx, db, cfg)
+	if err != nil || !cfg.DeleteAfterExport {
+		return m, err
+	}
+
+	// Read the files back before deleting anything.
+	if err := Verify(cfg.Dir, m); err != nil {
+		return m, err
+	}
+	for _, f := range m.Files {
+		if err := deleteChunk(ctx, db, m.MaxID, f); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// ExportTx is like Export but reads (and deletes) the rows in tx, typically
+// one holding LOCK TABLE logs so that no row is inserted while exporting.
+// With cfg.DeleteAfterExport, every row is deleted in tx: the caller commits.
+func ExportTx(ctx context.Context, tx *sql.Tx, cfg ExportConfig) (Manifest, error) {
+	m, err := export(ctx, tx, cfg)
+	if err != nil || !cfg.DeleteAfterExport {
+		return m, err
+	}
+
+	if err := Verify(cfg.Dir, m); err != nil {
+		return m, err
+	}
+	for _, f := range m.Files {
+		if err := deleteRows(ctx, tx, m.MaxID, f); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// querier is the part of *sql.DB and *sql.Tx used by exports.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// export writes the files and the manifest of cfg.
+func export(ctx context.Context, db querier, cfg ExportConfig) (Manifest, error) {
+	if cfg.Format == "" {
+		cfg.Format = NDJSON
+	}
+	ext, err := cfg.Format.ext()
+	if err != nil {
+		return Manifest{}, err
+	}
+	if cfg.Dir == "" {
+		return Manifest{}, errors.New("archive: Dir is required")
+	}
+	if cfg.Until.IsZero() {
+		return Manifest{}, errors.New("archive: Until is required")
+	}
+	if !cfg.Since.Before(cfg.Until) {
+		return Manifest{}, errors.New("archive: Since must be before Until")
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return Manifest{}, fmt.Errorf("archive: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Dir, ManifestName)); err == nil {
+		return Manifest{}, errManifestExists
+	}
+
+	m := Manifest{
+		Version:   1,
+		CreatedAt: time.Now().UTC(),
+		Format:    cfg.Format,
+		Since:     cfg.Since.UTC(),
+		Until:     cfg.Until.UTC(),
+		Files:     []File{},
+	}
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM logs`).Scan(&m.MaxID); err != nil {
+		return m, fmt.Errorf("archive: reading max id failed: %w", err)
+	}
+
+	since := m.Since
+	if cfg.Since.IsZero() {
+		if err := db.QueryRowContext(ctx, `SELECT COALESCE(MIN(ts), $1) FROM logs`, m.Until).Scan(&since); err != nil {
+			return m, fmt.Errorf("archive: reading min ts failed: %w", err)
+		}
+		since = since.UTC()
+	}
+
+	for from := since; from.Before(m.Until); {
+		to := from.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		if to.After(m.Until) {
+			to = m.Until
+		}
+
+		f := File{Name: "logs-" + from.Format(time.DateOnly) + ext, From: from, To: to}
+		if err := exportChunk(ctx, db, cfg, m.MaxID, &f); err != nil {
+			return m, err
+		}
+		if f.Rows > 0 {
+			m.Files = append(m.Files, f)
+		}
+		from = to
+	}
+
+	if err := writeManifest(cfg.Dir, m); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// exportChunk writes the rows of f's time range to f.Name and fills in its
+// row count, size and checksum. Nothing is written when there are no rows.
+func exportChunk(ctx context.Context, db querier, cfg ExportConfig, maxID int64, f *File) error {
+	rows, err := db.QueryContext(ctx, exportSQL, f.From, f.To, maxID)
+	if err != nil {
+		return fmt.Errorf("archive: export query failed: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return rows.Err()
+	}
+
+	h := sha256.New()
+	err = writeFileAtomic(filepath.Join(cfg.Dir, f.Name), func(w io.Writer) error {
+		cw := &countWriter{w: io.MultiWriter(w, h)}
+		rw, err := newWriter(cfg.Format, cw)
+		if err != nil {
+			return err
+		}
+		for {
+			var (
+				r              Record
+				reqID, traceID sql.NullString
+				raw            []byte
+			)
+			if err := rows.Scan(&r.ID, &r.TS, &reqID, &traceID, &raw); err != nil {
+				return err
+			}
+			r.TS = r.TS.UTC()
+			r.ReqID, r.TraceID, r.Raw = reqID.String, traceID.String, raw
+			if err := rw.Write(r); err != nil {
+				return err
+			}
+			f.Rows++
+			if !rows.Next() {
+				break
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := rw.Close(); err != nil {
+			return err
+		}
+		f.Bytes = cw.n
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// deleteChunk deletes the rows exported to f in a transaction of its own.
+func deleteChunk(ctx context.Context, db *sql.DB, maxID int64, f File) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteRows(ctx, tx, maxID, f); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
+
+// deleteRows deletes the rows exported to f in tx, failing if their number
+// differs from f.Rows. The caller rolls tx back on error.
+func deleteRows(ctx context.Context, tx *sql.Tx, maxID int64, f File) error {
+	res, err := tx.ExecContext(ctx, deleteSQL, f.From, f.To, maxID)
+	if err != nil {
+		return fmt.Errorf("archive: deleting rows of %s failed: %w", f.Name, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	if n != f.Rows {
+		return fmt.Errorf("archive: %s holds %d rows but %d would be deleted, rows kept", f.Name, f.Rows, n)
+	}
+	return nil
+}
+
+// countWriter counts the bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ZiplEix/better-logs/pgcore"
+)
+
+func TestExport_RequiresUntil(t *testing.T) {
+	_, err := Export(context.Background(), nil, ExportConfig{Dir: t.TempDir()})
+	if err == nil {
+		t.Errorf("expected an error without Until")
+	}
+}
+
+func TestImport_RejectsDuplicateColumns(t *testing.T) {
+	cols := []pgcore.Column{{Name: "user_id"}, {Name: "user_id", Keys: []string{"uid"}}}
+	if _, err := Import(context.Background(), nil, ImportConfig{Dir: t.TempDir(), Columns: cols}); err == nil {
+		t.Errorf("expected an error for duplicate columns")
+	}
+}
+
+func TestExport_DeleteAndImport(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const seed = `
+CREATE TABLE IF NOT EXISTS logs (
+    id      BIGSERIAL PRIMARY KEY,
+    ts      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    req_id  TEXT,
+    trace_id TEXT,
+    raw     JSONB NOT NULL
+);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS trace_id TEXT;
+DELETE FROM logs;
+DROP TABLE IF EXISTS logs_restored;
+INSERT INTO logs (ts, req_id, trace_id, raw) VALUES
+    ('2025-01-01T10:00:00Z', 'r-1', 't-1', '{"msg":"a"}'),
+    ('2025-01-01T23:59:59Z', NULL,  NULL,  '{"msg":"b"}'),
+    ('2025-01-03T00:00:00Z', 'r-2', NULL,  '{"msg":"c"}'),
+    ('2025-01-05T00:00:00Z', NULL,  NULL,  '{"msg":"kept"}');
+`
+	if _, err := db.ExecContext(ctx, seed); err != nil {
+		t.Fatalf("failed to seed logs: %v", err)
+	}
+
+	for _, format := range []Format{NDJSON, Parquet} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			m, err := Export(ctx, db, ExportConfig{
+				Dir:               dir,
+				Since:             time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				Until:             time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC),
+				Format:            format,
+				DeleteAfterExport: true,
+			})
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if len(m.Files) != 2 || m.Files[0].Rows != 2 || m.Files[1].Rows != 1 {
+				t.Fatalf("unexpected files: %+v", m.Files)
+			}
+
+			var left int
+			if err := db.QueryRowContext(ctx, `SELECT count(*) FROM logs`).Scan(&left); err != nil {
+				t.Fatal(err)
+			}
+			if left != 1 {
+				t.Errorf("expected 1 row left, got %d", left)
+			}
+
+			if _, err := Export(ctx, db, ExportConfig{Dir: dir, Until: time.Now()}); err == nil {
+				t.Errorf("expected an error when the directory holds an export")
+			}
+
+			n, err := Import(ctx, db, ImportConfig{
+				Dir:         dir,
+				Table:       "logs_restored",
+				CreateTable: true,
+				KeepIDs:     true,
+				Columns:     []pgcore.Column{{Name: "message", Keys: []string{"msg"}}},
+			})
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if n != 3 {
+				t.Errorf("expected 3 imported rows, got %d", n)
+			}
+
+			var reqID, message string
+			if err := db.QueryRowContext(ctx, `SELECT req_id, message FROM logs_restored WHERE raw->>'msg' = 'c'`).Scan(&reqID, &message); err != nil {
+				t.Fatalf("reading restored row failed: %v", err)
+			}
+			if reqID != "r-2" || message != "c" {
+				t.Errorf("expected req_id r-2 and the promoted message c, got %q %q", reqID, message)
+			}
+
+			// Put the rows back for the next format.
+			if _, err := db.ExecContext(ctx, `INSERT INTO logs (id, ts, req_id, trace_id, raw) SELECT id, ts, req_id, trace_id, raw FROM logs_restored; DROP TABLE logs_restored`); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	betterlogs "github.com/ZiplEix/better-logs"
+	"github.com/ZiplEix/better-logs/pgcore"
+	"github.com/lib/pq"
+)
+
+// ImportConfig controls Import.
+type ImportConfig struct {
+	// Dir is the directory of the export to import.
+	Dir string
+
+	// Table is the table the rows are inserted into. If empty, "logs" is
+	// used.
+	Table string
+
+	// CreateTable creates the table with the schema and indexes of the logs
+	// table (see betterlogs.LogsTableDDL) if it does not exist.
+	CreateTable bool
+
+	// KeepIDs inserts the exported ids instead of letting the table assign
+	// new ones. Import fails if one of them is already used.
+	KeepIDs bool
+
+	// Columns are the promoted columns of the table (see
+	// pgcore.Config.Columns), filled from the raw payload of each row as
+	// pgcore does. They must exist in the table unless CreateTable is set.
+	// Exports hold the payload only, so promoted columns left out are NULL.
+	Columns []pgcore.Column
+}
+
+// Import verifies the checksums of the export in cfg.Dir, then inserts its rows
+// with COPY, one transaction per file. It returns the number of inserted rows.
+func Import(ctx context.Context, db *sql.DB, cfg ImportConfig) (int64, error) {
+	if cfg.Dir == "" {
+		return 0, errors.New("archive: Dir is required")
+	}
+	if cfg.Table == "" {
+		cfg.Table = "logs"
+	}
+	if err := pgcore.ValidateColumns(cfg.Columns); err != nil {
+		return 0, fmt.Errorf("archive: %w", err)
+	}
+
+	m, err := ReadManifest(cfg.Dir)
+	if err != nil {
+		return 0, err
+	}
+	if err := Verify(cfg.Dir, m); err != nil {
+		return 0, err
+	}
+
+	table := pq.QuoteIdentifier(cfg.Table)
+	if cfg.CreateTable {
+		if _, err := db.ExecContext(ctx, betterlogs.LogsTableDDL(cfg.Table, cfg.Columns...)); err != nil {
+			return 0, fmt.Errorf("archive: creating table %s failed: %w", table, err)
+		}
+	}
+
+	var total int64
+	for _, f := range m.Files {
+		n, err := importFile(ctx, db, cfg, m.Format, f)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if cfg.KeepIDs && total > 0 {
+		// Move the sequence past the imported ids so new rows do not collide.
+		q := `SELECT setval(pg_get_serial_sequence($1, 'id'), (SELECT MAX(id) FROM ` + table + `))`
+		if _, err := db.ExecContext(ctx, q, table); err != nil {
+			return total, fmt.Errorf("archive: updating id sequence failed: %w", err)
+		}
+	}
+	return total, nil
+}
+
+// importFile copies the rows of f into the table.
+func importFile(ctx context.Context, db *sql.DB, cfg ImportConfig, format Format, f File) (int64, error) {
+	file, err := os.Open(filepath.Join(cfg.Dir, f.Name))
+	if err != nil {
+		return 0, fmt.Errorf("archive: %w", err)
+	}
+	defer file.Close()
+
+	rr, err := newReader(format, file)
+	if err != nil {
+		return 0, err
+	}
+	defer rr.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("archive: %w", err)
+	}
+	defer tx.Rollback()
+
+	columns := []string{"ts", "req_id", "trace_id"}
+	if cfg.KeepIDs {
+		columns = append([]string{"id"}, columns...)
+	}
+	for _, c := range cfg.Columns {
+		columns = append(columns, c.Name)
+	}
+	columns = append(columns, "raw")
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(cfg.Table, columns...))
+	if err != nil {
+		return 0, fmt.Errorf("archive: preparing COPY failed: %w", err)
+	}
+
+	var n int64
+	for {
+		r, err := rr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = stmt.Close()
+			return 0, fmt.Errorf("archive: reading %s failed: %w", f.Name, err)
+		}
+
+		args := []any{r.TS, nullString(r.ReqID), nullString(r.TraceID)}
+		if cfg.KeepIDs {
+			args = append([]any{r.ID}, args...)
+		}
+		if len(cfg.Columns) > 0 {
+			fields := payloadFields(r.Raw)
+			for _, c := range cfg.Columns {
+				args = append(args, c.Value(fields))
+			}
+		}
+		args = append(args, string(r.Raw))
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			_ = stmt.Close()
+			return 0, fmt.Errorf("archive: COPY of %s failed: %w", f.Name, err)
+		}
+		n++
+	}
+	if n != f.Rows {
+		_ = stmt.Close()
+		return 0, fmt.Errorf("archive: %s holds %d rows, manifest says %d", f.Name, n, f.Rows)
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return 0, fmt.Errorf("archive: COPY of %s failed: %w", f.Name, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, fmt.Errorf("archive: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("archive: %w", err)
+	}
+	return n, nil
+}
+
+// payloadFields decodes the top level keys of a payload as pgcore does, or
+// returns nil if it is not a JSON object.
+func payloadFields(raw []byte) map[string]any {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// nullString maps "" to NULL, as stored by pgcore for missing ids.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,17 @@
+// Command betterlogs manages the better-logs Postgres table: migrations,
+// retention, search, tail, stats and archives.
+//
+//	betterlogs <command> [flags]
+//
+// Run "betterlogs help" for the list of commands.
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args))
+}
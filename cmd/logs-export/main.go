@@ -0,0 +1,13 @@
+// Command logs-export archives logs to compressed files. It is the same as
+// "betterlogs export".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("export", os.Args))
+}
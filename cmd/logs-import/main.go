@@ -0,0 +1,13 @@
+// Command logs-import reloads an archive into a table. It is the same as
+// "betterlogs import".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("import", os.Args))
+}
@@ -0,0 +1,13 @@
+// Command logs-indexes lists, creates or drops the optional indexes of the logs
+// table. It is the same as "betterlogs indexes".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("indexes", os.Args))
+}
@@ -0,0 +1,12 @@
+// Command logs-migrate creates the logs table and indexes. It is the same as "betterlogs migrate".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("migrate", os.Args))
+}
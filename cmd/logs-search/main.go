@@ -0,0 +1,12 @@
+// Command logs-search searches the logs table. It is the same as "betterlogs search".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("search", os.Args))
+}
@@ -0,0 +1,13 @@
+// Command logs-stats reports the size and content of the logs table. It is the
+// same as "betterlogs stats".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("stats", os.Args))
+}
@@ -0,0 +1,12 @@
+// Command logs-tail follows the logs table. It is the same as "betterlogs tail".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("tail", os.Args))
+}
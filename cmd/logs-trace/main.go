@@ -0,0 +1,13 @@
+// Command logs-trace prints the log lines of a request as a timeline. It is the
+// same as "betterlogs trace".
+package main
+
+import (
+	"os"
+
+	"github.com/ZiplEix/better-logs/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.MainCommand("trace", os.Args))
+}
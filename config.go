@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/ZiplEix/better-logs/pgcore"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -29,6 +30,21 @@ type Config struct {
 		BatchSize  int           // number of log lines per COPY batch.
 		MaxWait    time.Duration // max wait before flushing batch.
 		BufferSize int           // channel buffer size.
+
+		// Columns lists extra columns filled from the log fields, e.g.
+		// user_id or status, so they can be filtered with an index instead of
+		// through the raw JSONB. See pgcore.Column. They are created by
+		// AutoMigrate and checked by New.
+		Columns []pgcore.Column
+
+		// AutoMigrate creates or upgrades the logs table with EnsureLogsTable
+		// when New is called. Either way, New checks the table exists with the
+		// expected columns and fails otherwise.
+		AutoMigrate bool
+
+		// StartupTimeout bounds the migration and check run by New.
+		// If zero or negative, 10s is used.
+		StartupTimeout time.Duration
 	}
 }
 
@@ -44,6 +60,7 @@ func DefaultConfig() Config {
 	cfg.PG.BatchSize = 1000
 	cfg.PG.MaxWait = 5 * time.Second
 	cfg.PG.BufferSize = 10_000
+	cfg.PG.StartupTimeout = 10 * time.Second
 
 	return cfg
 }
@@ -0,0 +1,422 @@
+// Package grpcmw provides gRPC interceptors logging calls the same way httpmw
+// logs HTTP requests.
+package grpcmw
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZiplEix/better-logs/httpmw"
+	"github.com/ZiplEix/better-logs/logctx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Config controls how the gRPC interceptors behave.
+type Config struct {
+	// Logger is the logger used for call logs, also returned by logctx.Logger
+	// in server handlers. If nil, zap.L() is used.
+	Logger *zap.Logger
+
+	// ServerMessage is the message of server call logs.
+	// If empty, "grpc_request" is used.
+	ServerMessage string
+
+	// ClientMessage is the message of client call logs.
+	// If empty, "grpc_client_request" is used.
+	ClientMessage string
+
+	// LevelFunc maps a gRPC status code to the level of the call log.
+	// If nil, DefaultCodeToLevel is used.
+	LevelFunc func(code codes.Code) zapcore.Level
+
+	// RequestIDMetadataKey is the metadata key the request ID is read from,
+	// echoed back in the response header and propagated by client interceptors.
+	// If empty, "x-request-id" is used.
+	RequestIDMetadataKey string
+
+	// RequestIDGenerator creates a request ID when the incoming call has none.
+	// If nil, httpmw.NewUUIDv7 is used.
+	RequestIDGenerator func() string
+
+	// GenerateSpanID makes server interceptors create a child span id for each
+	// call, and start a new trace when the call has no valid traceparent.
+	GenerateSpanID bool
+}
+
+// DefaultConfig returns a sane default configuration.
+func DefaultConfig() Config {
+	return Config{
+		ServerMessage:        "grpc_request",
+		ClientMessage:        "grpc_client_request",
+		LevelFunc:            DefaultCodeToLevel,
+		RequestIDMetadataKey: "x-request-id",
+	}
+}
+
+// withDefaults fills the zero fields of cfg.
+func (cfg Config) withDefaults() Config {
+	def := DefaultConfig()
+	if cfg.ServerMessage == "" {
+		cfg.ServerMessage = def.ServerMessage
+	}
+	if cfg.ClientMessage == "" {
+		cfg.ClientMessage = def.ClientMessage
+	}
+	if cfg.LevelFunc == nil {
+		cfg.LevelFunc = def.LevelFunc
+	}
+	if cfg.RequestIDMetadataKey == "" {
+		cfg.RequestIDMetadataKey = def.RequestIDMetadataKey
+	}
+	cfg.RequestIDMetadataKey = strings.ToLower(cfg.RequestIDMetadataKey)
+	if cfg.RequestIDGenerator == nil {
+		cfg.RequestIDGenerator = httpmw.NewUUIDv7
+	}
+	return cfg
+}
+
+// DefaultCodeToLevel logs client errors at info, conditions worth a look at
+// warn and server failures at error.
+func DefaultCodeToLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound,
+		codes.AlreadyExists, codes.Unauthenticated:
+		return zapcore.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// UnaryServerInterceptor returns a server interceptor logging unary calls.
+func UnaryServerInterceptor(cfg Config) grpc.UnaryServerInterceptor {
+	cfg = cfg.withDefaults()
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = cfg.serverContext(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(cfg.RequestIDMetadataKey, httpmw.RequestIDFrom(ctx)))
+
+		resp, err := handler(ctx, req)
+
+		var sent int64
+		if err == nil {
+			sent = 1
+		}
+		cfg.log(ctx, cfg.ServerMessage, info.FullMethod, start, err, peerAddr(ctx), 1, sent)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a server interceptor logging streaming calls
+// once they end.
+func StreamServerInterceptor(cfg Config) grpc.StreamServerInterceptor {
+	cfg = cfg.withDefaults()
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := cfg.serverContext(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(cfg.RequestIDMetadataKey, httpmw.RequestIDFrom(ctx)))
+
+		ws := &serverStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, ws)
+
+		cfg.log(ctx, cfg.ServerMessage, info.FullMethod, start, err, peerAddr(ctx), ws.received, ws.sent)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor logging unary calls and
+// propagating the request ID and trace context of ctx.
+func UnaryClientInterceptor(cfg Config) grpc.UnaryClientInterceptor {
+	cfg = cfg.withDefaults()
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		var p peer.Peer
+		err := invoker(cfg.outgoingContext(ctx), method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+
+		// grpc only fills p once the call got a transport stream: without it
+		// the call failed before the request was sent.
+		var received, sent int64
+		if err == nil {
+			received = 1
+		}
+		if err == nil || p.Addr != nil {
+			sent = 1
+		}
+		cfg.log(ctx, cfg.ClientMessage, method, start, err, addrString(&p), received, sent)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a client interceptor logging streaming calls
+// once they end and propagating the request ID and trace context of ctx.
+func StreamClientInterceptor(cfg Config) grpc.StreamClientInterceptor {
+	cfg = cfg.withDefaults()
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		p := &peer.Peer{}
+		cs, err := streamer(cfg.outgoingContext(ctx), desc, cc, method, append(opts, grpc.Peer(p))...)
+		if err != nil {
+			cfg.log(ctx, cfg.ClientMessage, method, start, err, addrString(p), 0, 0)
+			return nil, err
+		}
+
+		// The stream context carries the peer; p is only filled once the
+		// call ends, possibly concurrently with watch.
+		addr := peerAddr(cs.Context())
+		s := &clientStream{
+			ClientStream:  cs,
+			serverStreams: desc.ServerStreams,
+			finish: func(err error, received, sent int64) {
+				cfg.log(ctx, cfg.ClientMessage, method, start, err, addr, received, sent)
+			},
+		}
+		go s.watch(ctx)
+		return s, nil
+	}
+}
+
+// serverContext enriches the incoming context with a fields holder, the
+// logger, the request ID and the trace context read from the metadata.
+func (cfg Config) serverContext(ctx context.Context) context.Context {
+	ctx = logctx.WithFields(ctx, nil)
+	if cfg.Logger != nil {
+		ctx = logctx.WithLogger(ctx, cfg.Logger)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	reqID := firstValue(md, cfg.RequestIDMetadataKey)
+	if !httpmw.ValidRequestID(reqID) {
+		reqID = cfg.RequestIDGenerator()
+	}
+	ctx = httpmw.ContextWithRequestID(ctx, reqID)
+
+	tc, err := httpmw.ParseTraceparent(firstValue(md, httpmw.TraceparentHeader))
+	switch {
+	case err == nil:
+		if state, err := httpmw.ParseTracestate(md.Get(httpmw.TracestateHeader)...); err == nil {
+			tc.State = state
+		}
+		if cfg.GenerateSpanID {
+			tc = tc.Child()
+		}
+		ctx = httpmw.ContextWithTraceContext(ctx, tc)
+	case cfg.GenerateSpanID:
+		ctx = httpmw.ContextWithTraceContext(ctx, httpmw.NewTraceContext())
+	}
+
+	return ctx
+}
+
+// outgoingContext adds the request ID and trace context of ctx to the outgoing
+// metadata, unless already set.
+func (cfg Config) outgoingContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	var kv []string
+	if id := httpmw.RequestIDFrom(ctx); id != "" && len(md.Get(cfg.RequestIDMetadataKey)) == 0 {
+		kv = append(kv, cfg.RequestIDMetadataKey, id)
+	}
+	if tc, ok := httpmw.TraceContextFrom(ctx); ok && len(md.Get(httpmw.TraceparentHeader)) == 0 {
+		kv = append(kv, httpmw.TraceparentHeader, tc.Traceparent())
+		if tc.State != "" {
+			kv = append(kv, httpmw.TracestateHeader, tc.State)
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// log writes the call log.
+func (cfg Config) log(ctx context.Context, msg, fullMethod string, start time.Time, err error, peerAddr string, received, sent int64) {
+	lat := time.Since(start)
+	code := status.Code(err)
+	service, method := splitMethod(fullMethod)
+
+	fields := []zap.Field{
+		zap.String("grpc_service", service),
+		zap.String("grpc_method", method),
+		zap.String("grpc_code", code.String()),
+		zap.Float64("latency_ms", float64(lat.Microseconds())/1000),
+		zap.Int64("msgs_received", received),
+		zap.Int64("msgs_sent", sent),
+	}
+	if peerAddr != "" {
+		fields = append(fields, zap.String("peer", peerAddr))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	fields = append(fields, logctx.ZapFields(logctx.FieldsFrom(ctx))...)
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logctx.BaseLogger(ctx)
+	}
+	if ce := logger.Check(cfg.LevelFunc(code), msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream and counts messages.
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int64
+	sent     int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+	}
+	return err
+}
+
+// clientStream counts messages of a grpc.ClientStream and calls finish once
+// the stream ends: when RecvMsg or SendMsg report it, or when its context is
+// done while the caller is not reading (see watch).
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	finish        func(err error, received, sent int64)
+
+	mu       sync.Mutex
+	once     sync.Once
+	received int64
+	sent     int64
+	inFlight int // RecvMsg and SendMsg calls in progress
+}
+
+// watch ends the stream once its context is done. grpc cancels that context
+// when the call is canceled by the caller, when its deadline expires, when
+// the connection closes or when RecvMsg reads the final status; in the last
+// case the pending RecvMsg reports the outcome instead.
+func (s *clientStream) watch(callerCtx context.Context) {
+	<-s.ClientStream.Context().Done()
+
+	if err := callerCtx.Err(); err != nil {
+		s.end(status.FromContextError(err).Err())
+		return
+	}
+	s.mu.Lock()
+	pending := s.inFlight > 0
+	s.mu.Unlock()
+	if !pending {
+		s.end(status.FromContextError(s.ClientStream.Context().Err()).Err())
+	}
+}
+
+// begin marks a RecvMsg or SendMsg call in progress, until the returned
+// function is called.
+func (s *clientStream) begin() func() {
+	s.mu.Lock()
+	s.inFlight++
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	defer s.begin()()
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.sent++
+		s.mu.Unlock()
+	} else if err != io.EOF {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	defer s.begin()()
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	default:
+		s.mu.Lock()
+		s.received++
+		s.mu.Unlock()
+		// Without server streaming, the single response ends the call.
+		if !s.serverStreams {
+			s.end(nil)
+		}
+	}
+	return err
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		received, sent := s.received, s.sent
+		s.mu.Unlock()
+		s.finish(err, received, sent)
+	})
+}
+
+// splitMethod splits "/package.Service/Method" into service and method.
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+// peerAddr returns the address of the peer of an incoming call.
+func peerAddr(ctx context.Context) string {
+	p, _ := peer.FromContext(ctx)
+	return addrString(p)
+}
+
+func addrString(p *peer.Peer) string {
+	if p == nil || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+// firstValue returns the first value of key in md.
+func firstValue(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
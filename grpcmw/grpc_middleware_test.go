@@ -0,0 +1,307 @@
+package grpcmw
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZiplEix/better-logs/httpmw"
+	"github.com/ZiplEix/better-logs/logctx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// healthServer wraps the standard health server to inspect the handler context.
+type healthServer struct {
+	*health.Server
+
+	mu      sync.Mutex
+	lastCtx context.Context
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.mu.Lock()
+	s.lastCtx = ctx
+	s.mu.Unlock()
+	logctx.AddField(ctx, "user_id", "u-1")
+	return s.Server.Check(ctx, req)
+}
+
+func (s *healthServer) handlerContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastCtx
+}
+
+// startServer starts an in-process gRPC server with the interceptors and
+// returns a client connection using the client interceptors.
+func startServer(t *testing.T, cfg Config) (*grpc.ClientConn, *healthServer) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(cfg)),
+		grpc.StreamInterceptor(StreamServerInterceptor(cfg)),
+	)
+	hs := &healthServer{Server: health.NewServer()}
+	healthpb.RegisterHealthServer(srv, hs)
+
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(cfg)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(cfg)),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, hs
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	cfg := DefaultConfig()
+	cfg.Logger = zap.New(obs)
+
+	conn, hs := startServer(t, cfg)
+	client := healthpb.NewHealthClient(conn)
+
+	tc, _ := httpmw.ParseTraceparent(testTraceparent)
+	ctx := httpmw.ContextWithRequestID(context.Background(), "req-1")
+	ctx = httpmw.ContextWithTraceContext(ctx, tc)
+
+	var header metadata.MD
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("expected request id echoed in header, got %v", got)
+	}
+
+	hctx := hs.handlerContext()
+	if httpmw.RequestIDFrom(hctx) != "req-1" {
+		t.Errorf("expected propagated request id in handler, got %q", httpmw.RequestIDFrom(hctx))
+	}
+	if got, _ := httpmw.TraceContextFrom(hctx); got.TraceID != tc.TraceID || got.SpanID != tc.SpanID {
+		t.Errorf("expected propagated trace context, got %+v", got)
+	}
+
+	server := logs.FilterMessage("grpc_request").All()
+	if len(server) != 1 {
+		t.Fatalf("expected 1 server log, got %d", len(server))
+	}
+	fm := server[0].ContextMap()
+	if fm["grpc_service"] != "grpc.health.v1.Health" || fm["grpc_method"] != "Check" || fm["grpc_code"] != "OK" {
+		t.Errorf("unexpected method/code fields: %v", fm)
+	}
+	if fm["request_id"] != "req-1" || fm["trace_id"] != tc.TraceID || fm["user_id"] != "u-1" {
+		t.Errorf("expected context fields on server log, got %v", fm)
+	}
+	if fm["peer"] == nil || fm["msgs_received"] != int64(1) || fm["msgs_sent"] != int64(1) {
+		t.Errorf("unexpected peer/message counts: %v", fm)
+	}
+
+	client1 := logs.FilterMessage("grpc_client_request").All()
+	if len(client1) != 1 || client1[0].ContextMap()["request_id"] != "req-1" {
+		t.Fatalf("expected 1 client log with the request id, got %v", client1)
+	}
+}
+
+func TestUnaryInterceptors_ErrorLevelAndGeneratedID(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	cfg := DefaultConfig()
+	cfg.Logger = zap.New(obs)
+	cfg.RequestIDGenerator = func() string { return "generated" }
+
+	conn, hs := startServer(t, cfg)
+	client := healthpb.NewHealthClient(conn)
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	if httpmw.RequestIDFrom(hs.handlerContext()) != "generated" {
+		t.Errorf("expected generated request id")
+	}
+
+	server := logs.FilterMessage("grpc_request").All()
+	if len(server) != 1 {
+		t.Fatalf("expected 1 server log, got %d", len(server))
+	}
+	if server[0].Level != zapcore.InfoLevel || server[0].ContextMap()["grpc_code"] != "NotFound" {
+		t.Errorf("expected NotFound logged at info, got %v %v", server[0].Level, server[0].ContextMap()["grpc_code"])
+	}
+	if server[0].ContextMap()["error"] == nil {
+		t.Errorf("expected error field")
+	}
+
+	client1 := logs.FilterMessage("grpc_client_request").All()
+	if len(client1) != 1 {
+		t.Fatalf("expected 1 client log, got %d", len(client1))
+	}
+	if fm := client1[0].ContextMap(); fm["msgs_sent"] != int64(1) || fm["msgs_received"] != int64(0) {
+		t.Errorf("expected the request sent and no response, got %v", fm)
+	}
+}
+
+func TestUnaryClientInterceptor_ClosedConnection(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	cfg := DefaultConfig()
+	cfg.Logger = zap.New(obs)
+
+	lis := bufconn.Listen(1 << 20)
+	_ = lis.Close()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(cfg)),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+
+	entries := logs.FilterMessage("grpc_client_request").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 client log, got %d", len(entries))
+	}
+	fm := entries[0].ContextMap()
+	if fm["msgs_sent"] != int64(0) || fm["msgs_received"] != int64(0) || fm["peer"] != nil {
+		t.Errorf("expected no message sent nor peer, got %v", fm)
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	cfg := DefaultConfig()
+	cfg.Logger = zap.New(obs)
+	cfg.LevelFunc = func(code codes.Code) zapcore.Level {
+		if code == codes.Canceled {
+			return zapcore.DebugLevel
+		}
+		return DefaultCodeToLevel(code)
+	}
+
+	conn, _ := startServer(t, cfg)
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	cancel()
+	if _, err := stream.Recv(); err == nil || err == io.EOF {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+
+	// The server logs once its handler returns.
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("grpc_request").Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server := logs.FilterMessage("grpc_request").All()
+	if len(server) != 1 {
+		t.Fatalf("expected 1 server log, got %d", len(server))
+	}
+	fm := server[0].ContextMap()
+	if fm["grpc_method"] != "Watch" || fm["msgs_received"] != int64(1) || fm["msgs_sent"] != int64(1) {
+		t.Errorf("unexpected stream server fields: %v", fm)
+	}
+
+	client1 := logs.FilterMessage("grpc_client_request").All()
+	if len(client1) != 1 {
+		t.Fatalf("expected 1 client log, got %d", len(client1))
+	}
+	if client1[0].Level != zapcore.DebugLevel || client1[0].ContextMap()["grpc_code"] != "Canceled" {
+		t.Errorf("expected Canceled at debug, got %v %v", client1[0].Level, client1[0].ContextMap()["grpc_code"])
+	}
+	if client1[0].ContextMap()["msgs_received"] != int64(1) {
+		t.Errorf("expected 1 received message, got %v", client1[0].ContextMap()["msgs_received"])
+	}
+}
+
+func TestStreamClientInterceptor_LogsCanceledStreamWithoutRecv(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	cfg := DefaultConfig()
+	cfg.Logger = zap.New(obs)
+
+	conn, _ := startServer(t, cfg)
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	// Stop reading: the call only ends through its context.
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("grpc_client_request").Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// A late Recv must not log a second time.
+	_, _ = stream.Recv()
+
+	client1 := logs.FilterMessage("grpc_client_request").All()
+	if len(client1) != 1 {
+		t.Fatalf("expected 1 client log, got %d", len(client1))
+	}
+	fm := client1[0].ContextMap()
+	if fm["grpc_code"] != "Canceled" || fm["msgs_received"] != int64(1) || fm["peer"] == nil {
+		t.Errorf("expected a Canceled log with the peer and 1 received message, got %v", fm)
+	}
+}
+
+func TestDefaultCodeToLevel(t *testing.T) {
+	tests := map[codes.Code]zapcore.Level{
+		codes.OK:               zapcore.InfoLevel,
+		codes.NotFound:         zapcore.InfoLevel,
+		codes.DeadlineExceeded: zapcore.WarnLevel,
+		codes.Internal:         zapcore.ErrorLevel,
+		codes.Unavailable:      zapcore.ErrorLevel,
+	}
+	for code, want := range tests {
+		if got := DefaultCodeToLevel(code); got != want {
+			t.Errorf("DefaultCodeToLevel(%v) = %v, want %v", code, got, want)
+		}
+	}
+}
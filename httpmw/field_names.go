@@ -0,0 +1,84 @@
+package httpmw
+
+// FieldNames holds the keys used for the base fields of the request log.
+// Empty names fall back to DefaultFieldNames.
+type FieldNames struct {
+	Method    string
+	Path      string
+	Status    string
+	Latency   string // milliseconds, microsecond precision
+	RemoteIP  string
+	PeerIP    string
+	UserAgent string
+	Query     string
+	Body      string
+
+	// Only logged by Transport.
+	URL           string
+	RequestBytes  string
+	ResponseBytes string
+}
+
+// DefaultFieldNames returns the historical better-logs field names.
+func DefaultFieldNames() FieldNames {
+	return FieldNames{
+		Method:    "method",
+		Path:      "path",
+		Status:    "status",
+		Latency:   "latency_ms",
+		RemoteIP:  "remote_ip",
+		PeerIP:    "peer_ip",
+		UserAgent: "user_agent",
+		Query:     "query",
+		Body:      "body",
+
+		URL:           "url",
+		RequestBytes:  "request_bytes",
+		ResponseBytes: "response_bytes",
+	}
+}
+
+// OTelFieldNames returns field names following the OpenTelemetry HTTP semantic
+// conventions (which ECS shares for these attributes). They are flat keys
+// containing dots: double quote them in query paths, e.g.
+// `"http.request.method"=GET`.
+func OTelFieldNames() FieldNames {
+	return FieldNames{
+		Method:    "http.request.method",
+		Path:      "url.path",
+		Status:    "http.response.status_code",
+		Latency:   "latency_ms",
+		RemoteIP:  "client.address",
+		PeerIP:    "network.peer.address",
+		UserAgent: "user_agent.original",
+		Query:     "url.query",
+		Body:      "http.request.body.content",
+
+		URL:           "url.full",
+		RequestBytes:  "http.request.body.size",
+		ResponseBytes: "http.response.body.size",
+	}
+}
+
+// withDefaults fills empty names from DefaultFieldNames.
+func (fn FieldNames) withDefaults() FieldNames {
+	def := DefaultFieldNames()
+	fill := func(v *string, d string) {
+		if *v == "" {
+			*v = d
+		}
+	}
+	fill(&fn.Method, def.Method)
+	fill(&fn.Path, def.Path)
+	fill(&fn.Status, def.Status)
+	fill(&fn.Latency, def.Latency)
+	fill(&fn.RemoteIP, def.RemoteIP)
+	fill(&fn.PeerIP, def.PeerIP)
+	fill(&fn.UserAgent, def.UserAgent)
+	fill(&fn.Query, def.Query)
+	fill(&fn.Body, def.Body)
+	fill(&fn.URL, def.URL)
+	fill(&fn.RequestBytes, def.RequestBytes)
+	fill(&fn.ResponseBytes, def.ResponseBytes)
+	return fn
+}
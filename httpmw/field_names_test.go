@@ -0,0 +1,82 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMiddleware_CustomLoggerMessageFieldNamesAndLevels(t *testing.T) {
+	// The global logger must not be used when Config.Logger is set.
+	global := newCaptureCore(zapcore.DebugLevel)
+	zap.ReplaceGlobals(zap.New(global))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	obs, logs := observer.New(zapcore.DebugLevel)
+
+	cfg := DefaultConfig()
+	cfg.Logger = zap.New(obs)
+	cfg.Message = "request"
+	cfg.FieldNames = OTelFieldNames()
+	cfg.FieldNames.Latency = "" // falls back to the default name
+	cfg.LevelFunc = func(status int) zapcore.Level {
+		switch {
+		case status == 499:
+			return zapcore.DebugLevel
+		case status == http.StatusNotFound:
+			return zapcore.InfoLevel
+		default:
+			return statusLevel(status)
+		}
+	}
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Logger(r.Context()).Info("inside handler")
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/closed":
+			w.WriteHeader(499)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing?x=1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/closed", nil))
+
+	if n := len(global.Entries()); n != 0 {
+		t.Fatalf("expected no logs on the global logger, got %d", n)
+	}
+	if n := logs.FilterMessage("inside handler").Len(); n != 2 {
+		t.Errorf("expected Logger(ctx) to use Config.Logger, got %d handler logs", n)
+	}
+
+	reqs := logs.FilterMessage("request").All()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 request logs, got %d", len(reqs))
+	}
+
+	fm := reqs[0].ContextMap()
+	if reqs[0].Level != zapcore.InfoLevel {
+		t.Errorf("expected 404 logged at info, got %v", reqs[0].Level)
+	}
+	if fm["http.request.method"] != "GET" || fm["url.path"] != "/missing" || fm["url.query"] != "x=1" {
+		t.Errorf("expected OTel field names, got %v", fm)
+	}
+	if fm["http.response.status_code"] != int64(404) {
+		t.Errorf("expected http.response.status_code=404, got %v", fm["http.response.status_code"])
+	}
+	if _, ok := fm["latency_ms"]; !ok {
+		t.Errorf("expected empty Latency name to fall back to latency_ms")
+	}
+	if _, ok := fm["method"]; ok {
+		t.Errorf("unexpected default field name method")
+	}
+
+	if reqs[1].Level != zapcore.DebugLevel {
+		t.Errorf("expected 499 logged at debug, got %v", reqs[1].Level)
+	}
+}
@@ -4,86 +4,57 @@ import (
 	"bytes"
 	"context"
 	"io"
-	"net"
 	"net/http"
-	"strings"
-	"sync"
+	"net/netip"
+	"regexp"
 	"time"
 
+	"github.com/ZiplEix/better-logs/logctx"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-// ctxKey is the private key type used to store extra log fields in context.
-type ctxKey struct{}
-
-// fieldsHolder holds mutable log fields shared across middleware + handlers.
-type fieldsHolder struct {
-	mu sync.Mutex
-	m  map[string]any
-}
-
-// getHolder returns the fieldsHolder stored in the context, if any.
-func getHolder(ctx context.Context) *fieldsHolder {
-	if v := ctx.Value(ctxKey{}); v != nil {
-		if h, ok := v.(*fieldsHolder); ok {
-			return h
-		}
-	}
-	return nil
-}
-
-// ensureHolder returns an existing fieldsHolder from ctx or creates a new one
-// and returns the (possibly) updated context.
-func ensureHolder(ctx context.Context) (*fieldsHolder, context.Context) {
-	if h := getHolder(ctx); h != nil {
-		return h, ctx
-	}
-	h := &fieldsHolder{m: make(map[string]any)}
-	ctx = context.WithValue(ctx, ctxKey{}, h)
-	return h, ctx
-}
-
 // FieldsFrom returns a COPY of all custom log fields stored in the context, if any.
+// It is an alias of logctx.FieldsFrom.
 func FieldsFrom(ctx context.Context) map[string]any {
-	h := getHolder(ctx)
-	if h == nil {
-		return map[string]any{}
-	}
-
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	out := make(map[string]any, len(h.m))
-	for k, v := range h.m {
-		out[k] = v
-	}
-	return out
+	return logctx.FieldsFrom(ctx)
 }
 
 // WithFields merges the provided key/value pairs into the context's logging fields.
-// It returns the (possibly) updated context. The underlying holder is shared, so
-// even if the returned context is ignored, the fields are still added.
+// It is an alias of logctx.WithFields.
 func WithFields(ctx context.Context, kv map[string]any) context.Context {
-	h, ctx2 := ensureHolder(ctx)
-	if len(kv) == 0 {
-		return ctx2
-	}
-
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	for k, v := range kv {
-		h.m[k] = v
-	}
-	return ctx2
+	return logctx.WithFields(ctx, kv)
 }
 
 // AddField adds a single field into the context's logging fields.
+// It is an alias of logctx.AddField.
 func AddField(ctx context.Context, key string, value any) context.Context {
-	return WithFields(ctx, map[string]any{key: value})
+	return logctx.AddField(ctx, key, value)
 }
 
 // Config controls how the HTTP middleware behaves.
 type Config struct {
+	// Logger is the logger used for request logs and returned by Logger(ctx)
+	// for requests served by the middleware. If nil, zap.L() is used.
+	Logger *zap.Logger
+
+	// Message is the message of the request log. If empty, "http_request" is used.
+	Message string
+
+	// FieldNames renames the base fields of the request log (e.g. to
+	// OTelFieldNames()). Empty names fall back to DefaultFieldNames.
+	FieldNames FieldNames
+
+	// LevelFunc maps a response status to the level of the request log.
+	// If nil, 5xx are logged at error, 4xx at warn and the rest at info.
+	// Panics are always logged at error and slow requests may escalate the level.
+	LevelFunc func(status int) zapcore.Level
+
+	// Redaction masks sensitive query parameters and selects the request
+	// headers to log. Share it with Transport to get the same rules on
+	// outgoing calls. The zero value redacts nothing and logs no header.
+	Redaction Redaction
+
 	// LogRequestBody controls whether request bodies are logged.
 	// Be careful with large bodies and sensitive data (clear credentials...).
 	LogRequestBody bool
@@ -93,17 +64,97 @@ type Config struct {
 	MaxBodyBytes int64
 
 	// RemoteIPHeader, if non-empty, is the name of an HTTP header to trust for
-	// the client IP (e.g. "X-Real-IP" or "X-Forwarded-For").
+	// the client IP (e.g. "X-Real-IP", "X-Forwarded-For" or "Forwarded").
 	// If empty, r.RemoteAddr is used.
 	RemoteIPHeader string
+
+	// TrustedProxies lists the networks of the reverse proxies allowed to set
+	// RemoteIPHeader. When non-empty, the header is ignored unless the TCP peer
+	// is trusted, and X-Forwarded-For / Forwarded are walked right to left up to
+	// the first untrusted address. When empty, the header is trusted as-is
+	// (leftmost entry), which clients can spoof.
+	TrustedProxies []netip.Prefix
+
+	// RequestIDHeader is the header the request ID is read from and echoed
+	// back on the response. If empty, "X-Request-ID" is used.
+	RequestIDHeader string
+
+	// RequestIDGenerator creates a request ID when the incoming request has
+	// none. If nil, NewUUIDv7 is used.
+	RequestIDGenerator func() string
+
+	// GenerateSpanID makes the middleware create a child span id for each
+	// request (the incoming one is logged as parent_span_id), and start a new
+	// trace when the request has no valid traceparent header.
+	// If false, the incoming trace context (if any) is logged as-is.
+	GenerateSpanID bool
+
+	// Recover enables panic recovery: a panicking handler is logged at error
+	// level with status 500, the panic value and its stack, and RecoverResponse
+	// is written if the response headers were not sent yet.
+	// http.ErrAbortHandler is never swallowed: it is logged without stack
+	// (aborted=true) and re-panicked so the server aborts the response.
+	Recover bool
+
+	// RecoverResponse writes the response sent to the client after a recovered
+	// panic. If nil, a plain text 500 Internal Server Error is written.
+	RecoverResponse func(w http.ResponseWriter, r *http.Request, rec any)
+
+	// Repanic re-raises the recovered panic once it has been logged (e.g. to let
+	// an outer recovery layer or the http.Server handle it).
+	Repanic bool
+
+	// SkipPathPrefixes lists path prefixes (e.g. "/healthz", "/metrics") whose
+	// requests are served but not logged.
+	SkipPathPrefixes []string
+
+	// SkipPathPatterns lists regular expressions matched against the path of
+	// requests that are served but not logged.
+	SkipPathPatterns []*regexp.Regexp
+
+	// SkipMethods lists HTTP methods (e.g. "OPTIONS") that are not logged.
+	SkipMethods []string
+
+	// Skip, if non-nil, is called for every request; returning true skips the log.
+	Skip func(r *http.Request) bool
+
+	// Sampling lists sampling rules for successful requests. The first rule
+	// matching the request applies; requests matching no rule are all logged.
+	// Logged sampled requests carry sample_rate and sample_decision fields.
+	Sampling []SampleRule
+
+	// AlwaysLogStatus is the status from which sampled requests are always
+	// logged. If zero, 400 is used (i.e. only successful requests are sampled).
+	AlwaysLogStatus int
+
+	// AlwaysLogSlowerThan, if positive, makes sampled requests that took at
+	// least this long always logged.
+	AlwaysLogSlowerThan time.Duration
+
+	// SlowWarnThreshold, if positive, is the latency from which requests are
+	// logged at least at warn level with slow=true.
+	SlowWarnThreshold time.Duration
+
+	// SlowErrorThreshold, if positive, is the latency from which requests are
+	// logged at error level with slow=true.
+	SlowErrorThreshold time.Duration
+
+	// SlowRoutes overrides the slow thresholds per route. The first rule
+	// matching the request applies instead of the global thresholds.
+	// Slow requests are never sampled out.
+	SlowRoutes []SlowRule
 }
 
 // DefaultConfig returns a sane default configuration.
 func DefaultConfig() Config {
 	return Config{
-		LogRequestBody: false,
-		MaxBodyBytes:   64 * 1024, // 64KB
-		RemoteIPHeader: "",
+		Message:         "http_request",
+		FieldNames:      DefaultFieldNames(),
+		Redaction:       DefaultRedaction(),
+		LogRequestBody:  false,
+		MaxBodyBytes:    64 * 1024, // 64KB
+		RemoteIPHeader:  "",
+		RequestIDHeader: "X-Request-ID",
 	}
 }
 
@@ -114,9 +165,25 @@ func Middleware(next http.Handler) http.Handler {
 
 // WithConfig returns a net/http middleware using the provided configuration.
 func WithConfig(cfg Config) func(http.Handler) http.Handler {
+	if cfg.Message == "" {
+		cfg.Message = "http_request"
+	}
+	cfg.FieldNames = cfg.FieldNames.withDefaults()
+	if cfg.LevelFunc == nil {
+		cfg.LevelFunc = statusLevel
+	}
 	if cfg.MaxBodyBytes <= 0 {
 		cfg.MaxBodyBytes = 64 * 1024
 	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = "X-Request-ID"
+	}
+	if cfg.RecoverResponse == nil {
+		cfg.RecoverResponse = defaultRecoverResponse
+	}
+	if cfg.AlwaysLogStatus <= 0 {
+		cfg.AlwaysLogStatus = http.StatusBadRequest
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -125,6 +192,21 @@ func WithConfig(cfg Config) func(http.Handler) http.Handler {
 			// Ensure a shared fields holder exists in the context BEFORE the handler runs,
 			// so handlers can call AddField/WithFields and enrich the final http_request log.
 			ctxWithHolder := WithFields(r.Context(), nil)
+			if cfg.Logger != nil {
+				ctxWithHolder = logctx.WithLogger(ctxWithHolder, cfg.Logger)
+			}
+
+			// Reuse the incoming request ID or generate one, make it available to
+			// downstream code and echo it to the client.
+			reqID := resolveRequestID(r, cfg)
+			ctxWithHolder = ContextWithRequestID(ctxWithHolder, reqID)
+			w.Header().Set(cfg.RequestIDHeader, reqID)
+
+			// Attach the W3C trace context (trace_id/span_id) when available.
+			if tc, ok := requestTraceContext(r, cfg.GenerateSpanID); ok {
+				ctxWithHolder = ContextWithTraceContext(ctxWithHolder, tc)
+			}
+
 			r = r.WithContext(ctxWithHolder)
 
 			// Optionally read and buffer the request body
@@ -139,115 +221,114 @@ func WithConfig(cfg Config) func(http.Handler) http.Handler {
 				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(bodyBytes), r.Body))
 			}
 
-			// Wrap ResponseWriter to capture status and bytes written
-			rw := &responseWriter{
-				ResponseWriter: w,
-				status:         http.StatusOK,
+			// Wrap ResponseWriter to capture status and bytes written, while
+			// preserving the optional interfaces of the original writer.
+			rw, ww := wrapResponseWriter(w)
+
+			var (
+				rec   any
+				stack []byte
+			)
+			if cfg.Recover {
+				rec, stack = serveRecover(next, ww, r)
+			} else {
+				next.ServeHTTP(ww, r)
 			}
 
-			next.ServeHTTP(rw, r)
+			aborted := rec == http.ErrAbortHandler
+			panicked := rec != nil && !aborted
+			if panicked && !rw.wroteHeader {
+				cfg.RecoverResponse(ww, r, rec)
+			}
 
 			lat := time.Since(start)
 			status := rw.status
+			if panicked {
+				status = http.StatusInternalServerError
+			}
+
+			// Re-raise once the request has been logged (or skipped).
+			if aborted || (panicked && cfg.Repanic) {
+				defer panic(rec)
+			}
+
+			slowLvl, slow := cfg.slowLevel(r, lat)
+
+			// Apply skip rules and sampling; panics are always logged.
+			var sampleFields []zap.Field
+			if !panicked {
+				if cfg.skip(r) {
+					return
+				}
+				var keep bool
+				if sampleFields, keep = cfg.sample(r, status, lat, slow); !keep {
+					return
+				}
+			}
 
 			// Collect base fields
+			fn := cfg.FieldNames
 			fields := []zap.Field{
-				zap.String("method", r.Method),
-				zap.String("path", r.URL.Path),
-				zap.Int("status", status),
-				zap.Int64("latency_ms", lat.Milliseconds()),
-				zap.String("remote_ip", remoteIP(r, cfg.RemoteIPHeader)),
-				zap.String("user_agent", r.UserAgent()),
+				zap.String(fn.Method, r.Method),
+				zap.String(fn.Path, r.URL.Path),
+				zap.Int(fn.Status, status),
+				zap.Float64(fn.Latency, latencyMillis(lat)),
+				zap.String(fn.RemoteIP, remoteIP(r, cfg.RemoteIPHeader, cfg.TrustedProxies)),
+				zap.String(fn.PeerIP, peerIP(r)),
+				zap.String(fn.UserAgent, r.UserAgent()),
+			}
+
+			if slow {
+				fields = append(fields, zap.Bool("slow", true))
+			}
+
+			if rw.hijacked {
+				fields = append(fields, zap.Bool("hijacked", true))
+			}
+
+			if panicked {
+				fields = append(fields,
+					zap.Any("panic", rec),
+					zap.String("panic_stack", string(stack)),
+				)
 			}
+			if aborted {
+				fields = append(fields, zap.Bool("aborted", true))
+			}
+
+			fields = append(fields, sampleFields...)
 
 			if cfg.LogRequestBody && bodyStr != "" {
-				fields = append(fields, zap.String("body", bodyStr))
+				fields = append(fields, zap.String(fn.Body, bodyStr))
 			}
 
 			// Add query parameters as a string (optional, but often useful)
 			if rawQuery := r.URL.RawQuery; rawQuery != "" {
-				fields = append(fields, zap.String("query", rawQuery))
+				fields = append(fields, zap.String(fn.Query, cfg.Redaction.query(rawQuery)))
 			}
 
-			// Extract custom fields from context (if any)
+			if headers := cfg.Redaction.headers(r.Header); len(headers) > 0 {
+				fields = append(fields, zap.Any("request_headers", headers))
+			}
+
+			// Extract custom fields from context (if any), including the request ID
 			if ctxFields := FieldsFrom(r.Context()); len(ctxFields) > 0 {
 				for k, v := range ctxFields {
 					fields = append(fields, zap.Any(k, v))
 				}
 			}
 
-			// Try to infer a request/correlation ID from headers, if present
-			if reqID := headerRequestID(r); reqID != "" {
-				fields = append(fields, zap.String("request_id", reqID))
+			// Log with appropriate level: the status level, escalated for slow requests
+			lvl := cfg.LevelFunc(status)
+			if panicked {
+				lvl = zapcore.ErrorLevel
 			}
-
-			// Log with appropriate level
-			switch {
-			case status >= 500:
-				zap.L().Error("http_request", fields...)
-			case status >= 400:
-				zap.L().Warn("http_request", fields...)
-			default:
-				zap.L().Info("http_request", fields...)
+			if slow && slowLvl > lvl {
+				lvl = slowLvl
 			}
-		})
-	}
-}
-
-// responseWriter is a wrapper around http.ResponseWriter that captures status code
-// and bytes written.
-type responseWriter struct {
-	http.ResponseWriter
-	status int
-	size   int64
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.status = code
-	rw.ResponseWriter.WriteHeader(code)
-}
-
-func (rw *responseWriter) Write(b []byte) (int, error) {
-	n, err := rw.ResponseWriter.Write(b)
-	rw.size += int64(n)
-	return n, err
-}
-
-// remoteIP returns the IP address of the client, optionally using a trusted header.
-func remoteIP(r *http.Request, header string) string {
-	if header != "" {
-		if v := r.Header.Get(header); v != "" {
-			// X-Forwarded-For may contain a list: use the first value
-			if header == "X-Forwarded-For" {
-				parts := strings.Split(v, ",")
-				if len(parts) > 0 {
-					return strings.TrimSpace(parts[0])
-				}
+			if ce := logctx.BaseLogger(r.Context()).Check(lvl, cfg.Message); ce != nil {
+				ce.Write(fields...)
 			}
-			return v
-		}
-	}
-
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
-}
-
-// headerRequestID tries to extract a request/correlation ID from common headers.
-func headerRequestID(r *http.Request) string {
-	// common candidates
-	keys := []string{
-		"X-Request-ID",
-		"X-Correlation-ID",
-		"X-Request-Id",
-		"X-Requestid",
-	}
-	for _, k := range keys {
-		if v := r.Header.Get(k); v != "" {
-			return v
-		}
+		})
 	}
-	return ""
 }
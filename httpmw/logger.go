@@ -0,0 +1,22 @@
+package httpmw
+
+import (
+	"context"
+
+	"github.com/ZiplEix/better-logs/logctx"
+	"go.uber.org/zap"
+)
+
+// Logger returns the middleware logger (Config.Logger, or the global zap
+// logger) pre-bound with the fields currently stored in ctx (request id,
+// trace ids and any custom fields). It is an alias of logctx.Logger.
+func Logger(ctx context.Context) *zap.Logger {
+	return logctx.Logger(ctx)
+}
+
+// LazyLogger returns the middleware logger bound to the fields holder of ctx,
+// resolving context fields on every log call. It is an alias of
+// logctx.LazyLogger.
+func LazyLogger(ctx context.Context) *zap.Logger {
+	return logctx.LazyLogger(ctx)
+}
@@ -0,0 +1,88 @@
+package httpmw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger_BindsContextFields(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(obs))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := AddField(r.Context(), "user_id", "u-1")
+		Logger(ctx).Info("handler log")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("handler log").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 handler log, got %d", len(entries))
+	}
+
+	fm := entries[0].ContextMap()
+	if fm["request_id"] != "req-1" {
+		t.Errorf("expected request_id=req-1, got %v", fm["request_id"])
+	}
+	if fm["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || fm["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("unexpected trace fields: %v", fm)
+	}
+	if fm["user_id"] != "u-1" {
+		t.Errorf("expected user_id=u-1, got %v", fm["user_id"])
+	}
+}
+
+func TestLogger_EagerVsLazy(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(obs))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	ctx := WithFields(context.Background(), map[string]any{"a": 1})
+	eager := Logger(ctx)
+	lazy := LazyLogger(ctx).With(zap.String("bound", "yes"))
+
+	AddField(ctx, "b", 2)
+
+	eager.Info("eager")
+	lazy.Info("lazy")
+	lazy.Debug("filtered out")
+
+	eagerFields := logs.FilterMessage("eager").All()[0].ContextMap()
+	if eagerFields["a"] != int64(1) {
+		t.Errorf("expected a=1 on eager logger, got %v", eagerFields["a"])
+	}
+	if _, ok := eagerFields["b"]; ok {
+		t.Errorf("eager logger should not see fields added later")
+	}
+
+	lazyFields := logs.FilterMessage("lazy").All()[0].ContextMap()
+	if lazyFields["a"] != int64(1) || lazyFields["b"] != int64(2) || lazyFields["bound"] != "yes" {
+		t.Errorf("expected lazy logger to see a, b and bound fields, got %v", lazyFields)
+	}
+
+	if n := logs.FilterMessage("filtered out").Len(); n != 0 {
+		t.Errorf("expected debug log to be filtered, got %d", n)
+	}
+}
+
+func TestLogger_WithoutFieldsReturnsGlobal(t *testing.T) {
+	zap.ReplaceGlobals(zap.NewNop())
+
+	if Logger(context.Background()) != zap.L() {
+		t.Errorf("expected the global logger for an empty context")
+	}
+	if LazyLogger(context.Background()) != zap.L() {
+		t.Errorf("expected the global logger for a context without holder")
+	}
+}
@@ -0,0 +1,24 @@
+package httpmw
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// serveRecover calls next and recovers from a panic raised by it, returning the
+// panic value and the stack of the panicking goroutine.
+func serveRecover(next http.Handler, w http.ResponseWriter, r *http.Request) (rec any, stack []byte) {
+	defer func() {
+		if rec = recover(); rec != nil && rec != http.ErrAbortHandler {
+			stack = debug.Stack()
+		}
+	}()
+
+	next.ServeHTTP(w, r)
+	return nil, nil
+}
+
+// defaultRecoverResponse writes a plain text 500 response.
+func defaultRecoverResponse(w http.ResponseWriter, _ *http.Request, _ any) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
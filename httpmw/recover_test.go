@@ -0,0 +1,151 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMiddleware_RecoverLogsPanic(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.Recover = true
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddField(r.Context(), "user_id", "u-1")
+		panic("boom")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 response, got %d", rr.Code)
+	}
+
+	entries := core.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	if entries[0].Entry.Level != zapcore.ErrorLevel {
+		t.Errorf("expected Error level, got %v", entries[0].Entry.Level)
+	}
+
+	fm := fieldsToMap(entries[0].Fields)
+	if fm["status"] != int64(500) {
+		t.Errorf("expected status=500, got %v", fm["status"])
+	}
+	if fm["panic"] != "boom" {
+		t.Errorf("expected panic=boom, got %v", fm["panic"])
+	}
+	if s, _ := fm["panic_stack"].(string); !strings.Contains(s, "TestMiddleware_RecoverLogsPanic") {
+		t.Errorf("expected panic_stack to contain the panicking handler, got %q", s)
+	}
+	if fm["user_id"] != "u-1" || fm["request_id"] == nil {
+		t.Errorf("expected request fields on panic log, got %v", fm)
+	}
+}
+
+func TestMiddleware_RecoverCustomResponseAndHeadersSent(t *testing.T) {
+	zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.Recover = true
+	cfg.RecoverResponse = func(w http.ResponseWriter, r *http.Request, rec any) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"internal"}`))
+	}
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/late" {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		panic("boom")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/early", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Body.String() != `{"error":"internal"}` {
+		t.Errorf("expected custom response, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Headers already sent: the response is left untouched.
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/late", nil))
+	if rr.Code != http.StatusAccepted || rr.Body.Len() != 0 {
+		t.Errorf("expected untouched response, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMiddleware_RecoverRepanic(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.Recover = true
+	cfg.Repanic = true
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != "boom" {
+				t.Errorf("expected re-panic with boom, got %v", rec)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if n := len(core.Entries()); n != 1 {
+		t.Errorf("expected the panic to be logged before re-panicking, got %d entries", n)
+	}
+}
+
+func TestMiddleware_RecoverErrAbortHandler(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.Recover = true
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	rr := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if rec := recover(); rec != http.ErrAbortHandler {
+				t.Errorf("expected http.ErrAbortHandler to be re-panicked, got %v", rec)
+			}
+		}()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected no recover response, got %q", rr.Body.String())
+	}
+
+	entries := core.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	fm := fieldsToMap(entries[0].Fields)
+	if fm["aborted"] != true {
+		t.Errorf("expected aborted=true, got %v", fm["aborted"])
+	}
+	if _, ok := fm["panic_stack"]; ok {
+		t.Errorf("expected no panic_stack for http.ErrAbortHandler")
+	}
+}
@@ -0,0 +1,54 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRedaction_Query(t *testing.T) {
+	rd := DefaultRedaction()
+
+	tests := map[string]string{
+		"":                         "",
+		"a=1&b=2":                  "a=1&b=2",
+		"a=1&Token=abc&b":          "a=1&Token=[REDACTED]&b",
+		"access%5Ftoken=x&q=go+1":  "access%5Ftoken=[REDACTED]&q=go+1",
+		"password=&api_key=k&x=%z": "password=[REDACTED]&api_key=[REDACTED]&x=%z",
+	}
+	for in, want := range tests {
+		if got := rd.query(in); got != want {
+			t.Errorf("query(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if got := (Redaction{}).query("token=abc"); got != "token=abc" {
+		t.Errorf("expected zero Redaction to keep the query, got %q", got)
+	}
+}
+
+func TestMiddleware_RedactsQueryAndLogsAllowlistedHeaders(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.Redaction.HeaderAllowlist = []string{"content-type", "X-Tenant"}
+
+	req := httptest.NewRequest(http.MethodGet, "/cb?code=xyz&state=1", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Authorization", "Bearer secret")
+	WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+	fm := fieldsToMap(core.Entries()[0].Fields)
+	if fm["query"] != "code=[REDACTED]&state=1" {
+		t.Errorf("expected redacted query, got %v", fm["query"])
+	}
+	headers, _ := fm["request_headers"].(map[string]string)
+	if len(headers) != 1 || headers["Content-Type"] != "text/plain" {
+		t.Errorf("expected only Content-Type to be logged, got %v", fm["request_headers"])
+	}
+}
@@ -0,0 +1,144 @@
+package httpmw
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// peerIP returns the normalized IP address of the TCP peer of r.
+func peerIP(r *http.Request) string {
+	if addr, ok := parseIP(r.RemoteAddr); ok {
+		return addr.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// remoteIP returns the IP address of the client.
+//
+// Without trusted proxies, the header (if any) is trusted blindly, using the
+// leftmost X-Forwarded-For entry. With trusted proxies, the header is only
+// honored when the TCP peer is a trusted proxy, and list headers
+// (X-Forwarded-For, Forwarded) are walked right to left, skipping trusted
+// proxies, so that clients cannot spoof their address.
+func remoteIP(r *http.Request, header string, trusted []netip.Prefix) string {
+	peer := peerIP(r)
+	if header == "" {
+		return peer
+	}
+
+	values := r.Header.Values(header)
+	if len(values) == 0 {
+		return peer
+	}
+
+	var hops []string
+	switch http.CanonicalHeaderKey(header) {
+	case "X-Forwarded-For":
+		hops = splitList(strings.Join(values, ","))
+	case "Forwarded":
+		hops = forwardedFor(strings.Join(values, ","))
+	default:
+		hops = []string{strings.TrimSpace(values[len(values)-1])}
+	}
+	if len(hops) == 0 {
+		return peer
+	}
+
+	if len(trusted) == 0 {
+		if addr, ok := parseIP(hops[0]); ok {
+			return addr.String()
+		}
+		if hops[0] != "" {
+			return hops[0]
+		}
+		return peer
+	}
+
+	peerAddr, ok := parseIP(peer)
+	if !ok || !isTrusted(peerAddr, trusted) {
+		return peer
+	}
+
+	// Walk from the closest hop to the farthest one: the first address that is
+	// not a trusted proxy is the client. An unparseable hop (e.g. "unknown")
+	// cannot be trusted to carry anything further left.
+	client := peerAddr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseIP(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// isTrusted reports whether addr belongs to one of the trusted prefixes.
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP parses an IP address optionally wrapped in quotes or brackets and
+// followed by a port or an IPv6 zone, and normalizes IPv4-mapped IPv6
+// addresses to IPv4.
+func parseIP(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().WithZone("").Unmap(), true
+	}
+	if strings.HasPrefix(s, "[") {
+		if end := strings.IndexByte(s, ']'); end > 0 {
+			s = s[1:end]
+		}
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.WithZone("").Unmap(), true
+}
+
+// splitList splits a comma separated header value into trimmed elements.
+func splitList(v string) []string {
+	parts := strings.Split(v, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// forwardedFor returns the "for" parameters of an RFC 7239 Forwarded header,
+// in hop order. Elements without a "for" parameter are kept as empty hops so
+// that the walk stops there.
+func forwardedFor(v string) []string {
+	var hops []string
+	for _, elem := range splitList(v) {
+		var hop string
+		for _, pair := range strings.Split(elem, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				hop = strings.Trim(value, `"`)
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
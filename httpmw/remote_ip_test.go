@@ -0,0 +1,97 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRemoteIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		header  string
+		values  []string
+		trusted []netip.Prefix
+		want    string
+	}{
+		{name: "no header", remote: "203.0.113.7:1234", want: "203.0.113.7"},
+		{name: "ipv6 peer", remote: "[2001:DB8::1%eth0]:443", want: "2001:db8::1"},
+		{name: "ipv4-mapped peer", remote: "[::ffff:192.0.2.1]:80", want: "192.0.2.1"},
+		{
+			name: "legacy leftmost xff", remote: "10.0.0.1:1", header: "X-Forwarded-For",
+			values: []string{"1.1.1.1, 2.2.2.2"}, want: "1.1.1.1",
+		},
+		{
+			name: "untrusted peer ignores header", remote: "203.0.113.7:1", header: "X-Forwarded-For",
+			values: []string{"1.1.1.1"}, trusted: trusted, want: "203.0.113.7",
+		},
+		{
+			name: "xff walked right to left", remote: "10.0.0.1:1", header: "X-Forwarded-For",
+			values: []string{"6.6.6.6, 198.51.100.9", "10.1.2.3"}, trusted: trusted, want: "198.51.100.9",
+		},
+		{
+			name: "all hops trusted", remote: "10.0.0.1:1", header: "X-Forwarded-For",
+			values: []string{"10.9.9.9, 10.1.1.1"}, trusted: trusted, want: "10.9.9.9",
+		},
+		{
+			name: "garbage hop stops the walk", remote: "10.0.0.1:1", header: "X-Forwarded-For",
+			values: []string{"6.6.6.6, unknown, 10.1.1.1"}, trusted: trusted, want: "10.1.1.1",
+		},
+		{
+			name: "forwarded header", remote: "10.0.0.1:1", header: "Forwarded",
+			values:  []string{`for=6.6.6.6, for="[2001:db8:cafe::17]:4711";proto=https`, `for=198.51.100.9;by=10.0.0.1`},
+			trusted: trusted, want: "198.51.100.9",
+		},
+		{
+			name: "forwarded ipv6 client", remote: "[2001:db8::1]:1", header: "forwarded",
+			values: []string{`for="[2001:db9::17]:4711"`}, trusted: trusted, want: "2001:db9::17",
+		},
+		{
+			name: "single value header", remote: "10.0.0.1:1", header: "X-Real-IP",
+			values: []string{"198.51.100.9"}, trusted: trusted, want: "198.51.100.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.values {
+				r.Header.Add(tt.header, v)
+			}
+			if got := remoteIP(r, tt.header, tt.trusted); got != tt.want {
+				t.Errorf("remoteIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware_LogsRemoteAndPeerIP(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.RemoteIPHeader = "X-Forwarded-For"
+	cfg.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.5:5555"
+	req.Header.Set("X-Forwarded-For", "spoofed, 198.51.100.9")
+	WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+	fm := fieldsToMap(core.Entries()[0].Fields)
+	if fm["remote_ip"] != "198.51.100.9" || fm["peer_ip"] != "10.0.0.5" {
+		t.Errorf("expected remote_ip=198.51.100.9 peer_ip=10.0.0.5, got %v / %v", fm["remote_ip"], fm["peer_ip"])
+	}
+}
@@ -0,0 +1,89 @@
+package httpmw
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ZiplEix/better-logs/internal/ids"
+)
+
+// RequestIDField is the context field (and log key) holding the request ID.
+// pgcore picks it up by default to populate the req_id column.
+const RequestIDField = "request_id"
+
+// MaxRequestIDLen bounds the length of an incoming request ID accepted as-is.
+const MaxRequestIDLen = 128
+
+// requestIDKey is the private key type used to store the request ID in context.
+type requestIDKey struct{}
+
+// RequestIDFrom returns the request ID stored in the context by the middleware,
+// or an empty string if there is none.
+func RequestIDFrom(ctx context.Context) string {
+	if v, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// ContextWithRequestID stores the request ID in the context and in its logging
+// fields. It is useful outside of the middleware (e.g. jobs, tests).
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return AddField(ctx, RequestIDField, id)
+}
+
+// NewUUIDv7 returns a random, time-ordered UUID (RFC 9562 version 7) in its
+// canonical textual form. It is the default request ID generator.
+func NewUUIDv7() string {
+	return ids.NewUUIDv7()
+}
+
+// resolveRequestID returns the request ID to use for r: the incoming one if it
+// looks sane, a freshly generated one otherwise.
+func resolveRequestID(r *http.Request, cfg Config) string {
+	if id := headerRequestID(r, cfg.RequestIDHeader); ValidRequestID(id) {
+		return id
+	}
+	if cfg.RequestIDGenerator != nil {
+		return cfg.RequestIDGenerator()
+	}
+	return NewUUIDv7()
+}
+
+// ValidRequestID reports whether an incoming request ID can be trusted as-is:
+// non-empty, at most MaxRequestIDLen long and made of printable ASCII only, so
+// it cannot be used to forge log lines or blow up the req_id index. The gRPC
+// interceptors apply the same rule.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// headerRequestID tries to extract a request/correlation ID from common headers,
+// checking the configured header first.
+func headerRequestID(r *http.Request, header string) string {
+	// common candidates
+	keys := []string{
+		header,
+		"X-Request-ID",
+		"X-Correlation-ID",
+		"X-Requestid",
+	}
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		if v := r.Header.Get(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package httpmw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var uuidV7Re = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUIDv7_FormatAndOrdering(t *testing.T) {
+	a := NewUUIDv7()
+	b := NewUUIDv7()
+
+	if !uuidV7Re.MatchString(a) {
+		t.Fatalf("invalid UUIDv7: %q", a)
+	}
+	if a == b {
+		t.Fatalf("expected distinct ids, got %q twice", a)
+	}
+	// The first 48 bits are the millisecond timestamp, so ids sort by time.
+	if a[:8] > b[:8] {
+		t.Fatalf("expected time-ordered ids, got %q then %q", a, b)
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+		if FieldsFrom(r.Context())[RequestIDField] != seen {
+			t.Errorf("expected request id in context fields")
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !uuidV7Re.MatchString(seen) {
+		t.Fatalf("expected generated UUIDv7 in context, got %q", seen)
+	}
+	if got := rr.Header().Get("X-Request-ID"); got != seen {
+		t.Errorf("expected response header %q, got %q", seen, got)
+	}
+
+	entries := core.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	if fm := fieldsToMap(entries[0].Fields); fm["request_id"] != seen {
+		t.Errorf("expected request_id=%q, got %v", seen, fm["request_id"])
+	}
+}
+
+func TestMiddleware_RequestIDCustomHeaderAndGenerator(t *testing.T) {
+	zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.RequestIDHeader = "X-Trace"
+	cfg.RequestIDGenerator = func() string { return "generated-1" }
+
+	var seen string
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}))
+
+	// Incoming id on the configured header is reused.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Trace", "incoming-42")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if seen != "incoming-42" || rr.Header().Get("X-Trace") != "incoming-42" {
+		t.Errorf("expected incoming id to be reused, got ctx=%q header=%q", seen, rr.Header().Get("X-Trace"))
+	}
+
+	// Invalid incoming ids are replaced by a generated one.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Trace", strings.Repeat("x", MaxRequestIDLen+1))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if seen != "generated-1" || rr.Header().Get("X-Trace") != "generated-1" {
+		t.Errorf("expected generated id, got ctx=%q header=%q", seen, rr.Header().Get("X-Trace"))
+	}
+}
+
+func TestRequestIDFrom_Empty(t *testing.T) {
+	if id := RequestIDFrom(context.Background()); id != "" {
+		t.Fatalf("expected empty request id, got %q", id)
+	}
+}
@@ -0,0 +1,224 @@
+package httpmw
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter is a wrapper around http.ResponseWriter that captures status code
+// and bytes written.
+//
+// It never exposes optional interfaces (http.Flusher, http.Hijacker, http.Pusher,
+// io.ReaderFrom) by itself: wrapResponseWriter decorates it with exactly the ones
+// the underlying writer implements, so type assertions in handlers keep behaving
+// as if the middleware was not there.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	// 1xx informational responses (e.g. 103 Early Hints) may be sent several
+	// times before the final status, except 101 which ends the HTTP exchange.
+	// Only the final status is recorded so the log entry reflects what the
+	// client eventually received.
+	if code >= 200 || code == http.StatusSwitchingProtocols {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter. It is used by
+// http.ResponseController to reach features the wrapper does not expose.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseWriter) flush() {
+	rw.wroteHeader = true
+	rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (rw *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := rw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		rw.hijacked = true
+		if !rw.wroteHeader {
+			// The handler takes over the connection (typically a WebSocket
+			// upgrade), so no regular status will ever be written.
+			rw.status = http.StatusSwitchingProtocols
+			rw.wroteHeader = true
+		}
+	}
+	return conn, brw, err
+}
+
+func (rw *responseWriter) push(target string, opts *http.PushOptions) error {
+	return rw.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+func (rw *responseWriter) readFrom(src io.Reader) (int64, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	rw.size += n
+	return n, err
+}
+
+// unwrapper is the part of *responseWriter every decorated writer exposes.
+type unwrapper interface {
+	http.ResponseWriter
+	Unwrap() http.ResponseWriter
+}
+
+type flusher struct{ rw *responseWriter }
+
+func (f flusher) Flush() { f.rw.flush() }
+
+type hijacker struct{ rw *responseWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return h.rw.hijack() }
+
+type pusher struct{ rw *responseWriter }
+
+func (p pusher) Push(target string, opts *http.PushOptions) error { return p.rw.push(target, opts) }
+
+type readerFrom struct{ rw *responseWriter }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) { return r.rw.readFrom(src) }
+
+// wrapResponseWriter wraps w into a *responseWriter and returns the writer that
+// should be passed to the next handler. The returned writer implements exactly
+// the optional interfaces implemented by w.
+func wrapResponseWriter(w http.ResponseWriter) (*responseWriter, http.ResponseWriter) {
+	rw := &responseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+
+	_, isFlusher := w.(http.Flusher)
+	_, isHijacker := w.(http.Hijacker)
+	_, isPusher := w.(http.Pusher)
+	_, isReaderFrom := w.(io.ReaderFrom)
+
+	var (
+		fl = flusher{rw}
+		hj = hijacker{rw}
+		pu = pusher{rw}
+		rf = readerFrom{rw}
+	)
+
+	switch {
+	case isFlusher && isHijacker && isPusher && isReaderFrom:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, fl, hj, pu, rf}
+	case isFlusher && isHijacker && isPusher:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{rw, fl, hj, pu}
+	case isFlusher && isHijacker && isReaderFrom:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, fl, hj, rf}
+	case isFlusher && isPusher && isReaderFrom:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{rw, fl, pu, rf}
+	case isHijacker && isPusher && isReaderFrom:
+		return rw, struct {
+			unwrapper
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, hj, pu, rf}
+	case isFlusher && isHijacker:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+		}{rw, fl, hj}
+	case isFlusher && isPusher:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Pusher
+		}{rw, fl, pu}
+	case isFlusher && isReaderFrom:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+		}{rw, fl, rf}
+	case isHijacker && isPusher:
+		return rw, struct {
+			unwrapper
+			http.Hijacker
+			http.Pusher
+		}{rw, hj, pu}
+	case isHijacker && isReaderFrom:
+		return rw, struct {
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, hj, rf}
+	case isPusher && isReaderFrom:
+		return rw, struct {
+			unwrapper
+			http.Pusher
+			io.ReaderFrom
+		}{rw, pu, rf}
+	case isFlusher:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+		}{rw, fl}
+	case isHijacker:
+		return rw, struct {
+			unwrapper
+			http.Hijacker
+		}{rw, hj}
+	case isPusher:
+		return rw, struct {
+			unwrapper
+			http.Pusher
+		}{rw, pu}
+	case isReaderFrom:
+		return rw, struct {
+			unwrapper
+			io.ReaderFrom
+		}{rw, rf}
+	default:
+		return rw, rw
+	}
+}
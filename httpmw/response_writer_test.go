@@ -0,0 +1,244 @@
+package httpmw
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// plainWriter implements only http.ResponseWriter.
+type plainWriter struct {
+	header http.Header
+	status int
+	body   strings.Builder
+}
+
+func newPlainWriter() *plainWriter {
+	return &plainWriter{header: make(http.Header)}
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *plainWriter) WriteHeader(code int)        { w.status = code }
+
+// fullWriter implements every optional interface preserved by the middleware.
+type fullWriter struct {
+	*plainWriter
+	flushed  bool
+	hijacked bool
+	pushed   string
+	readFrom bool
+	deadline time.Time
+}
+
+func (w *fullWriter) Flush() { w.flushed = true }
+
+func (w *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	c1, c2 := net.Pipe()
+	_ = c2.Close()
+	return c1, bufio.NewReadWriter(bufio.NewReader(c1), bufio.NewWriter(c1)), nil
+}
+
+func (w *fullWriter) Push(target string, _ *http.PushOptions) error {
+	w.pushed = target
+	return nil
+}
+
+func (w *fullWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(&w.body, src)
+}
+
+func (w *fullWriter) SetWriteDeadline(t time.Time) error {
+	w.deadline = t
+	return nil
+}
+
+// hijackOnlyWriter implements http.Hijacker but no other optional interface.
+type hijackOnlyWriter struct {
+	*plainWriter
+	full *fullWriter
+}
+
+func (w hijackOnlyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.full.Hijack()
+}
+
+func serveWrapped(t *testing.T, w http.ResponseWriter, h http.HandlerFunc) []logEntry {
+	t.Helper()
+
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/rw", nil)
+	Middleware(h).ServeHTTP(w, req)
+
+	return core.Entries()
+}
+
+func TestResponseWriter_PlainWriterExposesNoOptionalInterfaces(t *testing.T) {
+	serveWrapped(t, newPlainWriter(), func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); ok {
+			t.Errorf("unexpected http.Flusher")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Errorf("unexpected http.Hijacker")
+		}
+		if _, ok := w.(http.Pusher); ok {
+			t.Errorf("unexpected http.Pusher")
+		}
+		if _, ok := w.(io.ReaderFrom); ok {
+			t.Errorf("unexpected io.ReaderFrom")
+		}
+	})
+}
+
+func TestResponseWriter_Flusher(t *testing.T) {
+	under := &fullWriter{plainWriter: newPlainWriter()}
+
+	entries := serveWrapped(t, under, func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatalf("expected http.Flusher")
+		}
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		f.Flush()
+	})
+
+	if !under.flushed {
+		t.Errorf("expected Flush to reach the underlying writer")
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+}
+
+func TestResponseWriter_Hijacker(t *testing.T) {
+	full := &fullWriter{plainWriter: newPlainWriter()}
+	under := hijackOnlyWriter{plainWriter: full.plainWriter, full: full}
+
+	entries := serveWrapped(t, under, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); ok {
+			t.Errorf("unexpected http.Flusher")
+		}
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatalf("expected http.Hijacker")
+		}
+		conn, _, err := hj.Hijack()
+		if err != nil {
+			t.Fatalf("Hijack returned error: %v", err)
+		}
+		_ = conn.Close()
+	})
+
+	if !full.hijacked {
+		t.Errorf("expected Hijack to reach the underlying writer")
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+
+	fm := fieldsToMap(entries[0].Fields)
+	if fm["hijacked"] != true {
+		t.Errorf("expected hijacked=true, got %v", fm["hijacked"])
+	}
+	if fm["status"] != int64(http.StatusSwitchingProtocols) {
+		t.Errorf("expected status=101, got %v", fm["status"])
+	}
+}
+
+func TestResponseWriter_Pusher(t *testing.T) {
+	under := &fullWriter{plainWriter: newPlainWriter()}
+
+	serveWrapped(t, under, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := w.(http.Pusher)
+		if !ok {
+			t.Fatalf("expected http.Pusher")
+		}
+		if err := p.Push("/app.js", nil); err != nil {
+			t.Fatalf("Push returned error: %v", err)
+		}
+	})
+
+	if under.pushed != "/app.js" {
+		t.Errorf("expected push target /app.js, got %q", under.pushed)
+	}
+}
+
+func TestResponseWriter_ReaderFrom(t *testing.T) {
+	under := &fullWriter{plainWriter: newPlainWriter()}
+
+	entries := serveWrapped(t, under, func(w http.ResponseWriter, r *http.Request) {
+		rf, ok := w.(io.ReaderFrom)
+		if !ok {
+			t.Fatalf("expected io.ReaderFrom")
+		}
+		if _, err := rf.ReadFrom(strings.NewReader("hello")); err != nil {
+			t.Fatalf("ReadFrom returned error: %v", err)
+		}
+	})
+
+	if !under.readFrom {
+		t.Errorf("expected ReadFrom to reach the underlying writer")
+	}
+	if under.body.String() != "hello" {
+		t.Errorf("expected body hello, got %q", under.body.String())
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+}
+
+func TestResponseWriter_ResponseControllerUnwrap(t *testing.T) {
+	under := &fullWriter{plainWriter: newPlainWriter()}
+	deadline := time.Now().Add(time.Minute)
+
+	serveWrapped(t, under, func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(deadline); err != nil {
+			t.Fatalf("SetWriteDeadline returned error: %v", err)
+		}
+		if err := rc.Flush(); err != nil {
+			t.Fatalf("Flush returned error: %v", err)
+		}
+	})
+
+	if !under.deadline.Equal(deadline) {
+		t.Errorf("expected write deadline to reach the underlying writer")
+	}
+	if !under.flushed {
+		t.Errorf("expected Flush to reach the underlying writer")
+	}
+}
+
+func TestResponseWriter_InformationalStatusNotLogged(t *testing.T) {
+	under := newPlainWriter()
+
+	entries := serveWrapped(t, under, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	if entries[0].Entry.Level != zapcore.InfoLevel {
+		t.Errorf("expected info level, got %v", entries[0].Entry.Level)
+	}
+	fm := fieldsToMap(entries[0].Fields)
+	if st, _ := fm["status"].(int64); st != http.StatusOK {
+		t.Errorf("expected status=200 after 103 Early Hints, got %v", fm["status"])
+	}
+}
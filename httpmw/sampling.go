@@ -0,0 +1,101 @@
+package httpmw
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Route selects the requests a rule applies to. The zero value matches every
+// request.
+type Route struct {
+	// PathPrefix restricts the rule to paths starting with it.
+	// If empty, every path matches.
+	PathPrefix string
+
+	// Method restricts the rule to an HTTP method. If empty, every method matches.
+	Method string
+}
+
+func (rt Route) matches(r *http.Request) bool {
+	if rt.Method != "" && !strings.EqualFold(rt.Method, r.Method) {
+		return false
+	}
+	return strings.HasPrefix(r.URL.Path, rt.PathPrefix)
+}
+
+// SampleRule defines the fraction of successful requests logged for the
+// requests it matches.
+type SampleRule struct {
+	Route
+
+	// Rate is the fraction of matching requests that are logged, between 0
+	// (none) and 1 (all).
+	Rate float64
+}
+
+// Sampling decisions recorded in the sample_decision field.
+const (
+	sampleDecisionSampled = "sampled"
+	sampleDecisionError   = "always_error"
+	sampleDecisionSlow    = "always_slow"
+)
+
+// skip reports whether r matches one of the skip rules.
+func (cfg *Config) skip(r *http.Request) bool {
+	for _, m := range cfg.SkipMethods {
+		if strings.EqualFold(m, r.Method) {
+			return true
+		}
+	}
+	for _, p := range cfg.SkipPathPrefixes {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	for _, re := range cfg.SkipPathPatterns {
+		if re.MatchString(r.URL.Path) {
+			return true
+		}
+	}
+	return cfg.Skip != nil && cfg.Skip(r)
+}
+
+// sample applies the sampling rules to a finished request. It reports whether
+// the request must be logged and returns the fields recording the decision.
+func (cfg *Config) sample(r *http.Request, status int, lat time.Duration, slow bool) ([]zap.Field, bool) {
+	rule, ok := cfg.sampleRule(r)
+	if !ok || rule.Rate >= 1 {
+		return nil, true
+	}
+
+	var decision string
+	switch {
+	case status >= cfg.AlwaysLogStatus:
+		decision = sampleDecisionError
+	case slow || (cfg.AlwaysLogSlowerThan > 0 && lat >= cfg.AlwaysLogSlowerThan):
+		decision = sampleDecisionSlow
+	case rule.Rate > 0 && rand.Float64() < rule.Rate:
+		decision = sampleDecisionSampled
+	default:
+		return nil, false
+	}
+
+	return []zap.Field{
+		zap.Float64("sample_rate", rule.Rate),
+		zap.String("sample_decision", decision),
+	}, true
+}
+
+// sampleRule returns the first sampling rule matching r.
+func (cfg *Config) sampleRule(r *http.Request) (SampleRule, bool) {
+	for _, rule := range cfg.Sampling {
+		if rule.matches(r) {
+			return rule, true
+		}
+	}
+	return SampleRule{}, false
+}
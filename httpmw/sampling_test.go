@@ -0,0 +1,141 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMiddleware_SkipRules(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.SkipPathPrefixes = []string{"/healthz"}
+	cfg.SkipPathPatterns = []*regexp.Regexp{regexp.MustCompile(`^/static/.*\.css$`)}
+	cfg.SkipMethods = []string{http.MethodOptions}
+	cfg.Skip = func(r *http.Request) bool { return r.Header.Get("X-Probe") != "" }
+
+	served := 0
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	skipped := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/healthz/live", nil),
+		httptest.NewRequest(http.MethodGet, "/static/app.css", nil),
+		httptest.NewRequest(http.MethodOptions, "/api", nil),
+	}
+	probe := httptest.NewRequest(http.MethodGet, "/api", nil)
+	probe.Header.Set("X-Probe", "1")
+	skipped = append(skipped, probe)
+
+	for _, req := range skipped {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+
+	if served != len(skipped)+1 {
+		t.Errorf("expected every request to be served, got %d", served)
+	}
+
+	entries := core.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected only the non-skipped request to be logged, got %d entries", len(entries))
+	}
+	if fm := fieldsToMap(entries[0].Fields); fm["path"] != "/static/app.js" {
+		t.Errorf("expected /static/app.js to be logged, got %v", fm["path"])
+	}
+}
+
+func TestMiddleware_SamplingWithOverrides(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.Sampling = []SampleRule{
+		{Route: Route{PathPrefix: "/metrics"}, Rate: 0},
+		{Route: Route{PathPrefix: "/api", Method: http.MethodGet}, Rate: 1},
+	}
+	cfg.AlwaysLogSlowerThan = 20 * time.Millisecond
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("mode") {
+		case "error":
+			w.WriteHeader(http.StatusBadGateway)
+		case "slow":
+			time.Sleep(25 * time.Millisecond)
+		}
+	}))
+
+	for _, target := range []string{
+		"/metrics",            // sampled out
+		"/metrics?mode=error", // always logged: error
+		"/metrics?mode=slow",  // always logged: slow
+		"/api",                // rate 1: logged without sampling fields
+		"/other",              // no rule: logged
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	entries := core.Entries()
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 log entries, got %d", len(entries))
+	}
+
+	decisions := map[string]any{}
+	for _, e := range entries {
+		fm := fieldsToMap(e.Fields)
+		key, _ := fm["path"].(string)
+		if q, ok := fm["query"].(string); ok {
+			key += "?" + q
+		}
+		decisions[key] = fm["sample_decision"]
+		if fm["sample_decision"] != nil && fm["sample_rate"] != float64(0) {
+			t.Errorf("expected sample_rate=0 for %s, got %v", key, fm["sample_rate"])
+		}
+	}
+
+	want := map[string]any{
+		"/metrics?mode=error": "always_error",
+		"/metrics?mode=slow":  "always_slow",
+		"/api":                nil,
+		"/other":              nil,
+	}
+	for k, v := range want {
+		got, ok := decisions[k]
+		if !ok {
+			t.Errorf("expected %s to be logged", k)
+			continue
+		}
+		if got != v {
+			t.Errorf("expected sample_decision=%v for %s, got %v", v, k, got)
+		}
+	}
+}
+
+func TestMiddleware_PanicIgnoresSkipRules(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.Recover = true
+	cfg.SkipPathPrefixes = []string{"/"}
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if n := len(core.Entries()); n != 1 {
+		t.Fatalf("expected the panic to be logged despite skip rules, got %d entries", n)
+	}
+}
@@ -0,0 +1,59 @@
+package httpmw
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SlowRule overrides the global latency thresholds for the requests it matches.
+type SlowRule struct {
+	Route
+
+	// Warn is the latency from which the request is logged at least at warn
+	// level. Zero disables it.
+	Warn time.Duration
+
+	// Error is the latency from which the request is logged at error level.
+	// Zero disables it.
+	Error time.Duration
+}
+
+// slowLevel returns the level a request is escalated to because of its
+// latency, and whether it is considered slow at all.
+func (cfg *Config) slowLevel(r *http.Request, lat time.Duration) (zapcore.Level, bool) {
+	warn, errAfter := cfg.SlowWarnThreshold, cfg.SlowErrorThreshold
+	for _, rule := range cfg.SlowRoutes {
+		if rule.matches(r) {
+			warn, errAfter = rule.Warn, rule.Error
+			break
+		}
+	}
+
+	switch {
+	case errAfter > 0 && lat >= errAfter:
+		return zapcore.ErrorLevel, true
+	case warn > 0 && lat >= warn:
+		return zapcore.WarnLevel, true
+	default:
+		return zapcore.InfoLevel, false
+	}
+}
+
+// statusLevel maps a response status to a log level.
+func statusLevel(status int) zapcore.Level {
+	switch {
+	case status >= 500:
+		return zapcore.ErrorLevel
+	case status >= 400:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// latencyMillis returns lat in milliseconds with microsecond precision.
+func latencyMillis(lat time.Duration) float64 {
+	return float64(lat.Microseconds()) / 1000
+}
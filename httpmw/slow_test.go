@@ -0,0 +1,75 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLatencyMillis_MicrosecondPrecision(t *testing.T) {
+	if got := latencyMillis(1234567 * time.Nanosecond); got != 1.234 {
+		t.Fatalf("expected 1.234ms, got %v", got)
+	}
+	if got := latencyMillis(250 * time.Microsecond); got != 0.25 {
+		t.Fatalf("expected 0.25ms, got %v", got)
+	}
+}
+
+func TestMiddleware_SlowThresholds(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.SlowWarnThreshold = 10 * time.Millisecond
+	cfg.SlowErrorThreshold = time.Hour
+	cfg.SlowRoutes = []SlowRule{
+		{Route: Route{PathPrefix: "/reports"}, Warn: time.Hour},
+		{Route: Route{PathPrefix: "/critical"}, Error: 10 * time.Millisecond},
+	}
+	// Slow requests bypass sampling.
+	cfg.Sampling = []SampleRule{{Rate: 0}}
+
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(15 * time.Millisecond)
+		if r.URL.Path == "/reports/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	for _, path := range []string{"/api", "/reports", "/reports/missing", "/critical"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	levels := map[string]zapcore.Level{}
+	slow := map[string]any{}
+	for _, e := range core.Entries() {
+		fm := fieldsToMap(e.Fields)
+		path, _ := fm["path"].(string)
+		levels[path] = e.Entry.Level
+		slow[path] = fm["slow"]
+
+		if lat, ok := fm["latency_ms"].(float64); !ok || lat < 15 {
+			t.Errorf("expected float latency_ms >= 15 for %s, got %v", path, fm["latency_ms"])
+		}
+	}
+
+	// /reports has a per-route threshold it does not reach, so the sampling rule
+	// drops it; the 404 is kept as an error.
+	if _, ok := levels["/reports"]; ok {
+		t.Errorf("expected /reports to be sampled out")
+	}
+	if levels["/reports/missing"] != zapcore.WarnLevel || slow["/reports/missing"] != nil {
+		t.Errorf("expected /reports/missing at warn without slow, got %v slow=%v", levels["/reports/missing"], slow["/reports/missing"])
+	}
+	if levels["/api"] != zapcore.WarnLevel || slow["/api"] != true {
+		t.Errorf("expected /api escalated to warn with slow=true, got %v slow=%v", levels["/api"], slow["/api"])
+	}
+	if levels["/critical"] != zapcore.ErrorLevel || slow["/critical"] != true {
+		t.Errorf("expected /critical escalated to error with slow=true, got %v slow=%v", levels["/critical"], slow["/critical"])
+	}
+}
@@ -0,0 +1,286 @@
+package httpmw
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Log keys used for W3C Trace Context fields.
+const (
+	TraceIDField      = "trace_id"
+	SpanIDField       = "span_id"
+	ParentSpanIDField = "parent_span_id"
+)
+
+// Header names defined by the W3C Trace Context specification.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// Limits above which a tracestate header is dropped.
+const (
+	maxTracestateLen     = 512
+	maxTracestateMembers = 32
+)
+
+// ErrInvalidTraceparent is returned by ParseTraceparent for malformed headers.
+var ErrInvalidTraceparent = errors.New("httpmw: invalid traceparent")
+
+// ErrInvalidTracestate is returned by ParseTracestate for malformed headers.
+var ErrInvalidTracestate = errors.New("httpmw: invalid tracestate")
+
+// TraceContext holds the W3C Trace Context of a request.
+type TraceContext struct {
+	// TraceID is the 32 hex chars trace identifier.
+	TraceID string
+
+	// SpanID is the 16 hex chars identifier of the current span. When the
+	// middleware creates a child span, this is the new span id.
+	SpanID string
+
+	// ParentSpanID is the span id received from the caller, set only when a
+	// child span was created.
+	ParentSpanID string
+
+	// Flags holds the trace flags (bit 0 is "sampled").
+	Flags byte
+
+	// State is the raw (vendor specific) tracestate header, if any.
+	State string
+}
+
+// Sampled reports whether the sampled flag is set.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&0x01 == 0x01
+}
+
+// IsValid reports whether tc holds a usable trace and span id.
+func (tc TraceContext) IsValid() bool {
+	return validHexID(tc.TraceID, 32) && validHexID(tc.SpanID, 16)
+}
+
+// Traceparent formats tc as a version 00 traceparent header value.
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// Child returns a copy of tc with a new random span id, whose parent is the
+// current span.
+func (tc TraceContext) Child() TraceContext {
+	child := tc
+	child.ParentSpanID = tc.SpanID
+	child.SpanID = randomHexID(8)
+	return child
+}
+
+// NewTraceContext starts a new sampled trace with random trace and span ids.
+func NewTraceContext() TraceContext {
+	return TraceContext{
+		TraceID: randomHexID(16),
+		SpanID:  randomHexID(8),
+		Flags:   0x01,
+	}
+}
+
+// ParseTraceparent parses and validates a traceparent header value according
+// to the W3C Trace Context specification.
+func ParseTraceparent(v string) (TraceContext, error) {
+	v = strings.TrimSpace(v)
+
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	if len(v) < 55 || v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	version := v[0:2]
+	if !isLowerHex(version) || version == "ff" {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	// Version 00 has an exact length; future versions may append fields.
+	if version == "00" && len(v) != 55 {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	if len(v) > 55 && v[55] != '-' {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	traceID, spanID, flags := v[3:35], v[36:52], v[53:55]
+	if !validHexID(traceID, 32) || !validHexID(spanID, 16) || !isLowerHex(flags) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	b, _ := hex.DecodeString(flags)
+	return TraceContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Flags:   b[0],
+	}, nil
+}
+
+// ParseTracestate validates the tracestate header values (several headers are
+// combined as one list) according to the W3C Trace Context specification and
+// returns the list without its empty members. A header with a malformed or
+// duplicated member, more than 32 members or longer than 512 chars must be
+// dropped: ErrInvalidTracestate is returned.
+func ParseTracestate(values ...string) (string, error) {
+	state := strings.Join(values, ",")
+	if len(state) > maxTracestateLen {
+		return "", ErrInvalidTracestate
+	}
+
+	var members []string
+	keys := map[string]bool{}
+	for _, m := range strings.Split(state, ",") {
+		m = strings.Trim(m, " \t")
+		if m == "" {
+			// Empty members are allowed, see the specification.
+			continue
+		}
+		key, value, ok := strings.Cut(m, "=")
+		if !ok || !validTracestateKey(key) || !validTracestateValue(value) || keys[key] {
+			return "", ErrInvalidTracestate
+		}
+		keys[key] = true
+		members = append(members, m)
+	}
+	if len(members) > maxTracestateMembers {
+		return "", ErrInvalidTracestate
+	}
+	return strings.Join(members, ","), nil
+}
+
+// validTracestateKey reports whether key is a simple-key or a
+// tenant-id@system-id multi-tenant key.
+func validTracestateKey(key string) bool {
+	tenant, system, multi := strings.Cut(key, "@")
+	if !multi {
+		return len(key) <= 256 && startsWithLowerAlpha(key) && tracestateKeyChars(key)
+	}
+	return len(tenant) <= 241 && tracestateKeyChars(tenant) && !strings.ContainsAny(tenant[:1], "_-*/") &&
+		len(system) <= 14 && startsWithLowerAlpha(system) && tracestateKeyChars(system)
+}
+
+// tracestateKeyChars reports whether s is non-empty and only holds the
+// characters allowed in tracestate keys.
+func tracestateKeyChars(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && !strings.ContainsRune("_-*/", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func startsWithLowerAlpha(s string) bool {
+	return s != "" && s[0] >= 'a' && s[0] <= 'z'
+}
+
+// validTracestateValue reports whether v is 1 to 256 printable ASCII chars,
+// without "," and "=", not ending with a space.
+func validTracestateValue(v string) bool {
+	if v == "" || len(v) > 256 || v[len(v)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// traceContextKey is the private key type used to store the TraceContext.
+type traceContextKey struct{}
+
+// TraceContextFrom returns the TraceContext stored in the context, if any.
+func TraceContextFrom(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// traceContextFromFields returns the TraceContext stored in the context or,
+// failing that, one rebuilt from the trace_id/span_id context fields (marked
+// as sampled).
+func traceContextFromFields(ctx context.Context) (TraceContext, bool) {
+	if tc, ok := TraceContextFrom(ctx); ok {
+		return tc, true
+	}
+
+	fields := FieldsFrom(ctx)
+	traceID, _ := fields[TraceIDField].(string)
+	spanID, _ := fields[SpanIDField].(string)
+	tc := TraceContext{TraceID: traceID, SpanID: spanID, Flags: 0x01}
+	return tc, tc.IsValid()
+}
+
+// ContextWithTraceContext stores tc in the context and adds its ids to the
+// context logging fields.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	ctx = context.WithValue(ctx, traceContextKey{}, tc)
+
+	kv := map[string]any{
+		TraceIDField: tc.TraceID,
+		SpanIDField:  tc.SpanID,
+	}
+	if tc.ParentSpanID != "" {
+		kv[ParentSpanIDField] = tc.ParentSpanID
+	}
+	return WithFields(ctx, kv)
+}
+
+// requestTraceContext extracts the trace context of r. When newSpan is true a
+// child span is created (or a new trace started if r carries none).
+func requestTraceContext(r *http.Request, newSpan bool) (TraceContext, bool) {
+	tc, err := ParseTraceparent(r.Header.Get(TraceparentHeader))
+	if err != nil {
+		if !newSpan {
+			return TraceContext{}, false
+		}
+		return NewTraceContext(), true
+	}
+
+	// tracestate is only meaningful alongside a valid traceparent.
+	if state, err := ParseTracestate(r.Header.Values(TracestateHeader)...); err == nil {
+		tc.State = state
+	}
+
+	if newSpan {
+		tc = tc.Child()
+	}
+	return tc, true
+}
+
+// validHexID reports whether s is n lowercase hex chars and not all zeros.
+func validHexID(s string, n int) bool {
+	if len(s) != n || !isLowerHex(s) {
+		return false
+	}
+	return strings.Trim(s, "0") != ""
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHexID returns n random bytes hex encoded.
+func randomHexID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,179 @@
+package httpmw
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tc, err := ParseTraceparent(testTraceparent)
+	if err != nil {
+		t.Fatalf("ParseTraceparent returned error: %v", err)
+	}
+	if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID != "00f067aa0ba902b7" || !tc.Sampled() {
+		t.Fatalf("unexpected trace context: %+v", tc)
+	}
+	if tc.Traceparent() != testTraceparent {
+		t.Fatalf("expected round trip %q, got %q", testTraceparent, tc.Traceparent())
+	}
+
+	// Future versions may carry extra fields.
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Fatalf("expected future version to be accepted, got %v", err)
+	}
+
+	invalid := []string{
+		"",
+		"garbage",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",  // forbidden version
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",  // zero trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",  // zero span id
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",  // uppercase
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-", // v00 too long
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",  // bad flags
+	}
+	for _, v := range invalid {
+		if _, err := ParseTraceparent(v); err == nil {
+			t.Errorf("expected error for %q", v)
+		}
+	}
+}
+
+func TestParseTracestate(t *testing.T) {
+	valid := map[string]string{
+		"congo=t61rcWkgMzE":                   "congo=t61rcWkgMzE",
+		"rojo=00f067aa0ba902b7 , congo=t61rc": "rojo=00f067aa0ba902b7,congo=t61rc",
+		"tenant1@vendor=x,a_b-c*d/e=1":        "tenant1@vendor=x,a_b-c*d/e=1",
+		"a=1,,  ,b=2":                         "a=1,b=2", // empty members are skipped
+		"":                                    "",
+	}
+	for in, want := range valid {
+		got, err := ParseTracestate(in)
+		if err != nil || got != want {
+			t.Errorf("ParseTracestate(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if got, err := ParseTracestate("a=1", "b=2"); err != nil || got != "a=1,b=2" {
+		t.Errorf("expected several headers to be combined, got %q, %v", got, err)
+	}
+
+	members := make([]string, 33)
+	for i := range members {
+		members[i] = fmt.Sprintf("k%d=v", i)
+	}
+	invalid := map[string]string{
+		"uppercase key":    "Congo=x",
+		"key starts digit": "1abc=x",
+		"missing value":    "congo=",
+		"missing equals":   "congo",
+		"bad key char":     "con.go=x",
+		"bad value char":   "congo=a\x1bb",
+		"equals in value":  "congo=a=b",
+		"key too long":     strings.Repeat("k", 257) + "=x",
+		"empty tenant":     "@vendor=x",
+		"bad system":       "tenant@1vendor=x",
+		"duplicate key":    "a=1,a=2",
+		"too many members": strings.Join(members, ","),
+		"too long":         "a=" + strings.Repeat("x", 600),
+		"value too long":   "a=" + strings.Repeat("x", 257),
+		"invalid after ok": "a=1,,B=2",
+	}
+	for name, in := range invalid {
+		if _, err := ParseTracestate(in); !errors.Is(err, ErrInvalidTracestate) {
+			t.Errorf("%s: expected ErrInvalidTracestate for %q, got %v", name, in, err)
+		}
+	}
+}
+
+func TestMiddleware_DropsInvalidTracestate(t *testing.T) {
+	var got TraceContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = TraceContextFrom(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	req.Header.Set(TracestateHeader, "congo=\x1b[2J")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.TraceID == "" || got.State != "" {
+		t.Errorf("expected the trace context without its invalid tracestate, got %+v", got)
+	}
+}
+
+func TestMiddleware_LogsIncomingTraceContext(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	var got TraceContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = TraceContextFrom(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	req.Header.Set(TracestateHeader, "congo=t61rcWkgMzE")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.SpanID != "00f067aa0ba902b7" || got.State != "congo=t61rcWkgMzE" {
+		t.Fatalf("unexpected trace context in handler: %+v", got)
+	}
+
+	entries := core.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	fm := fieldsToMap(entries[0].Fields)
+	if fm["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || fm["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("unexpected trace fields: trace_id=%v span_id=%v", fm["trace_id"], fm["span_id"])
+	}
+	if _, ok := fm["parent_span_id"]; ok {
+		t.Errorf("unexpected parent_span_id without GenerateSpanID")
+	}
+}
+
+func TestMiddleware_GenerateSpanID(t *testing.T) {
+	core := newCaptureCore(zapcore.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer zap.ReplaceGlobals(zap.NewNop())
+
+	cfg := DefaultConfig()
+	cfg.GenerateSpanID = true
+	handler := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// With an incoming traceparent, a child span is created.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Without one, a new trace is started.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	entries := core.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 log entries, got %d", len(entries))
+	}
+
+	child := fieldsToMap(entries[0].Fields)
+	if child["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || child["parent_span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("unexpected child span fields: %v", child)
+	}
+	if s, _ := child["span_id"].(string); !validHexID(s, 16) || s == "00f067aa0ba902b7" {
+		t.Errorf("expected a new span id, got %v", child["span_id"])
+	}
+
+	root := fieldsToMap(entries[1].Fields)
+	if s, _ := root["trace_id"].(string); !validHexID(s, 32) {
+		t.Errorf("expected a new trace id, got %v", root["trace_id"])
+	}
+}
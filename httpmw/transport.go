@@ -0,0 +1,265 @@
+package httpmw
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ZiplEix/better-logs/logctx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Transport is an http.RoundTripper logging outgoing requests, the client side
+// counterpart of the middleware.
+//
+// Each call is logged with its method, redacted URL, status, latency, request
+// and response sizes, error (if any) and the fields of the request context.
+// The request ID and W3C traceparent found in the context are propagated on
+// the outgoing request, unless already set.
+//
+// Sizes are the bytes actually transferred, so chunked and streamed bodies are
+// measured too. As a consequence, a successful call is logged once its response
+// body has been read to the end or closed: callers must close it, as required
+// by net/http anyway. The latency is the time until the response headers.
+type Transport struct {
+	// Base is the RoundTripper performing the requests.
+	// If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Logger is the logger used for client logs. If nil, the logger of the
+	// middleware serving the request context (or zap.L()) is used.
+	Logger *zap.Logger
+
+	// Message is the message of the client log. If empty,
+	// "http_client_request" is used.
+	Message string
+
+	// FieldNames renames the base fields of the client log. Use the
+	// middleware's Config.FieldNames to share names. Empty names fall back
+	// to DefaultFieldNames.
+	FieldNames FieldNames
+
+	// LevelFunc maps a response status to the level of the client log.
+	// Use the middleware's Config.LevelFunc to share levels. If nil, 5xx are
+	// logged at error, 4xx at warn and the rest at info. Transport errors are
+	// always logged at error.
+	LevelFunc func(status int) zapcore.Level
+
+	// Redaction masks sensitive query parameters and selects the request
+	// headers to log. Use the middleware's Config.Redaction to share rules.
+	Redaction Redaction
+
+	// RequestIDHeader is the header carrying the propagated request ID.
+	// If empty, "X-Request-ID" is used.
+	RequestIDHeader string
+
+	// DisablePropagation disables setting the request ID and traceparent
+	// headers on outgoing requests.
+	DisablePropagation bool
+}
+
+// NewTransport returns a Transport wrapping base with DefaultFieldNames and
+// DefaultRedaction.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:       base,
+		FieldNames: DefaultFieldNames(),
+		Redaction:  DefaultRedaction(),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx := req.Context()
+	if !t.DisablePropagation {
+		req = t.propagate(req)
+	}
+	orig := req
+	req, reqBody := countRequestBody(req)
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	lat := time.Since(start)
+
+	fn := t.FieldNames.withDefaults()
+	fields := []zap.Field{
+		zap.String(fn.Method, orig.Method),
+		zap.String(fn.URL, t.Redaction.url(orig.URL)),
+		zap.Float64(fn.Latency, latencyMillis(lat)),
+	}
+	if headers := t.Redaction.headers(orig.Header); len(headers) > 0 {
+		fields = append(fields, zap.Any("request_headers", headers))
+	}
+
+	// requestBytes is read once the request has been sent. A declared length
+	// is preferred: bodies replayed through GetBody are not counted.
+	requestBytes := func() int64 {
+		if orig.ContentLength > 0 {
+			return orig.ContentLength
+		}
+		if reqBody != nil {
+			return reqBody.n.Load()
+		}
+		return 0
+	}
+
+	if err != nil {
+		if n := requestBytes(); n > 0 {
+			fields = append(fields, zap.Int64(fn.RequestBytes, n))
+		}
+		fields = append(fields, zap.Error(err))
+		t.write(ctx, zapcore.ErrorLevel, fields)
+		return resp, err
+	}
+
+	levelFunc := t.LevelFunc
+	if levelFunc == nil {
+		levelFunc = statusLevel
+	}
+	lvl := levelFunc(resp.StatusCode)
+	fields = append(fields, zap.Int(fn.Status, resp.StatusCode))
+
+	finish := func(respBytes int64, bodyErr error) {
+		if n := requestBytes(); n > 0 {
+			fields = append(fields, zap.Int64(fn.RequestBytes, n))
+		}
+		if respBytes >= 0 {
+			fields = append(fields, zap.Int64(fn.ResponseBytes, respBytes))
+		}
+		if bodyErr != nil {
+			fields = append(fields, zap.Error(bodyErr))
+		}
+		t.write(ctx, lvl, fields)
+	}
+
+	// A 101 body is the upgraded connection (an io.ReadWriteCloser): it is
+	// neither wrapped nor measured.
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		size := int64(0)
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			size = -1
+		}
+		finish(size, nil)
+		return resp, nil
+	}
+
+	resp.Body = &countingBody{ReadCloser: resp.Body, done: finish}
+	return resp, nil
+}
+
+// write logs the client log at lvl with the logger of the transport or ctx.
+func (t *Transport) write(ctx context.Context, lvl zapcore.Level, fields []zap.Field) {
+	fields = append(fields, logctx.ZapFields(FieldsFrom(ctx))...)
+
+	logger := t.Logger
+	if logger == nil {
+		logger = logctx.BaseLogger(ctx)
+	}
+	msg := t.Message
+	if msg == "" {
+		msg = "http_client_request"
+	}
+	if ce := logger.Check(lvl, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// requestCounter counts the bytes of a request body read by the base
+// RoundTripper, which may do so from another goroutine.
+type requestCounter struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *requestCounter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// countRequestBody returns a copy of req whose body is counted, or req itself
+// when it has no body or declares its length.
+func countRequestBody(req *http.Request) (*http.Request, *requestCounter) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength > 0 {
+		return req, nil
+	}
+	c := &requestCounter{ReadCloser: req.Body}
+	clone := req.Clone(req.Context())
+	clone.Body = c
+	return clone, c
+}
+
+// countingBody counts the bytes read from a response body and calls done
+// once, when the body reaches EOF, fails or is closed.
+type countingBody struct {
+	io.ReadCloser
+	n    atomic.Int64
+	once sync.Once
+	done func(n int64, err error)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	if err == io.EOF {
+		b.finish(nil)
+	} else if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(nil)
+	return err
+}
+
+func (b *countingBody) finish(err error) {
+	b.once.Do(func() { b.done(b.n.Load(), err) })
+}
+
+// propagate returns req with the request ID and traceparent of its context set
+// as headers. The original request is not modified.
+func (t *Transport) propagate(req *http.Request) *http.Request {
+	ctx := req.Context()
+
+	header := t.RequestIDHeader
+	if header == "" {
+		header = "X-Request-ID"
+	}
+
+	id := RequestIDFrom(ctx)
+	if id == "" {
+		id, _ = FieldsFrom(ctx)[RequestIDField].(string)
+	}
+
+	set := map[string]string{}
+	if id != "" && req.Header.Get(header) == "" {
+		set[header] = id
+	}
+	if tc, ok := traceContextFromFields(ctx); ok && req.Header.Get(TraceparentHeader) == "" {
+		set[TraceparentHeader] = tc.Traceparent()
+		if tc.State != "" {
+			set[TracestateHeader] = tc.State
+		}
+	}
+	if len(set) == 0 {
+		return req
+	}
+
+	req = req.Clone(ctx)
+	for k, v := range set {
+		req.Header.Set(k, v)
+	}
+	return req
+}
@@ -0,0 +1,186 @@
+package betterlogs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZiplEix/better-logs/pgcore"
+	"github.com/ZiplEix/better-logs/query"
+)
+
+func TestFieldIndex(t *testing.T) {
+	cases := map[string]Index{
+		"service": {Name: "idx_logs_raw_service_56dfde64", Definition: "USING btree ((raw->>'service'))"},
+		"user.id": {Name: "idx_logs_raw_user_id_483b348b", Definition: "USING btree ((raw->'user'->>'id'))"},
+		"O'Brien": {Name: "idx_logs_raw_o_brien_6a5d2bd5", Definition: "USING btree ((raw->>'O''Brien'))"},
+	}
+	for path, want := range cases {
+		got, err := FieldIndex(path)
+		if err != nil || got != want {
+			t.Errorf("FieldIndex(%q) = %+v, %v, want %+v", path, got, err, want)
+		}
+	}
+
+	// Paths sanitized or truncated to the same name get distinct indexes.
+	long := strings.Repeat("a", 60)
+	names := map[string]string{}
+	for _, path := range []string{"user.id", "user_id", long + ".x", long + ".y"} {
+		ix, err := FieldIndex(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ix.Name) > 63 {
+			t.Errorf("FieldIndex(%q) name %q is longer than 63 bytes", path, ix.Name)
+		}
+		if other, ok := names[ix.Name]; ok {
+			t.Errorf("FieldIndex(%q) and FieldIndex(%q) share the name %q", path, other, ix.Name)
+		}
+		names[ix.Name] = path
+	}
+	if quoted, _ := FieldIndex(`"user"."id"`); quoted != cases["user.id"] {
+		t.Errorf("expected a quoted path to give the same index, got %+v", quoted)
+	}
+
+	for _, path := range []string{"", "user.", ".id"} {
+		if _, err := FieldIndex(path); err == nil {
+			t.Errorf("FieldIndex(%q): expected an error", path)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	c := pgcore.Column{Name: "user_id", Index: true}
+	want := Index{Name: "idx_logs_user_id", Definition: `USING btree ("user_id")`}
+	if got := ColumnIndex(c); got != want {
+		t.Errorf("ColumnIndex(user_id) = %+v, want %+v", got, want)
+	}
+	// The same index as EnsureLogsTable, so that either can create it.
+	if ddl := columnsDDL("logs", []pgcore.Column{c}); !strings.Contains(ddl, `"idx_logs_user_id" ON "logs" ("user_id")`) {
+		t.Errorf("expected EnsureLogsTable to create %s, got %s", want.Name, ddl)
+	}
+}
+
+func TestCreateIndexes_NilDB(t *testing.T) {
+	if err := CreateIndexes(context.Background(), nil, IndexOptions{}, RawIndex()); err == nil {
+		t.Fatalf("expected error when db is nil, got nil")
+	}
+}
+
+func TestCreateIndexes_Concurrently(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := EnsureLogsTable(ctx, db); err != nil {
+		t.Fatalf("EnsureLogsTable failed: %v", err)
+	}
+
+	field, err := FieldIndex("service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Index{RawIndex(), TSBRINIndex(), field}
+	opts := IndexOptions{Concurrently: true}
+
+	for i := 0; i < 2; i++ {
+		if err := CreateIndexes(ctx, db, opts, want...); err != nil {
+			t.Fatalf("CreateIndexes call %d failed: %v", i+1, err)
+		}
+	}
+
+	list, err := ListIndexes(ctx, db)
+	if err != nil {
+		t.Fatalf("ListIndexes failed: %v", err)
+	}
+	valid := map[string]bool{}
+	for _, ix := range list {
+		valid[ix.Name] = ix.Valid
+	}
+	for _, ix := range want {
+		if !valid[ix.Name] {
+			t.Errorf("expected a valid index %s, got %+v", ix.Name, list)
+		}
+	}
+
+	names := make([]string, len(want))
+	for i, ix := range want {
+		names[i] = ix.Name
+	}
+	if err := DropIndexes(ctx, db, opts, names...); err != nil {
+		t.Fatalf("DropIndexes failed: %v", err)
+	}
+	list, err = ListIndexes(ctx, db)
+	if err != nil {
+		t.Fatalf("ListIndexes failed: %v", err)
+	}
+	for _, ix := range list {
+		if ix.Name == field.Name {
+			t.Errorf("expected %s to be dropped", ix.Name)
+		}
+	}
+}
+
+func TestFieldIndex_ServesFieldEquality(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := EnsureLogsTable(ctx, db); err != nil {
+		t.Fatalf("EnsureLogsTable failed: %v", err)
+	}
+	// The GIN index would serve the containment condition as well.
+	if err := DropIndexes(ctx, db, IndexOptions{}, RawIndex().Name); err != nil {
+		t.Fatalf("DropIndexes failed: %v", err)
+	}
+	field, err := FieldIndex("user.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateIndexes(ctx, db, IndexOptions{}, field); err != nil {
+		t.Fatalf("CreateIndexes failed: %v", err)
+	}
+	defer DropIndexes(context.Background(), db, IndexOptions{}, field.Name)
+
+	stmt, args, err := query.New().Field("user.id", query.Eq, "u-1").SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	// Leave only bitmap scans, which need an index matching a condition: the
+	// ts index cannot be used for the ordering.
+	if _, err := tx.ExecContext(ctx, `SET LOCAL enable_seqscan = off; SET LOCAL enable_indexscan = off`); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.QueryContext(ctx, "EXPLAIN "+stmt, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN failed: %v", err)
+	}
+	defer rows.Close()
+
+	var plan strings.Builder
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatal(err)
+		}
+		plan.WriteString(line + "\n")
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(plan.String(), field.Name) {
+		t.Errorf("expected the plan to use %s, got:\n%s", field.Name, plan.String())
+	}
+}
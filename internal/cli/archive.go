@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/ZiplEix/better-logs/archive"
+	"github.com/ZiplEix/better-logs/query"
+)
+
+var exportCmd = &Command{
+	Name:        "export",
+	Summary:     "archive logs to compressed NDJSON or Parquet files",
+	Usage:       "-dir <dir> -until <time> [-since <time>] [-format ndjson|parquet] [-delete-after-export]",
+	Description: "Exports logs to one compressed file per UTC day, with a manifest.json listing row counts and SHA-256 checksums.",
+	Examples: []string{
+		"-dir ./archive/2025-01 -since 2025-01-01T00:00:00Z -until 2025-02-01T00:00:00Z",
+		"-dir ./archive/old -until 2160h -format parquet -delete-after-export   # archive then purge logs older than 90 days",
+	},
+	Timeout: 30 * time.Minute,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		dir := fs.String("dir", "", "directory the files and manifest are written to (created if missing, must not hold an export)")
+		since := fs.String("since", "", "start of the exported range: RFC 3339 time or duration ago (e.g. '720h'); default: oldest row")
+		until := fs.String("until", "", "end of the exported range (excluded): RFC 3339 time or duration ago (e.g. '168h')")
+		format := fs.String("format", "ndjson", "file format: ndjson (gzip compressed) or parquet")
+		deleteAfter := fs.Bool("delete-after-export", false, "delete the exported rows once the files are written and verified")
+
+		return func(ctx context.Context, env *Env) error {
+			if *dir == "" {
+				return usageErrorf("-dir is required")
+			}
+			if *until == "" {
+				return usageErrorf("-until is required")
+			}
+
+			now := time.Now()
+			cfg := archive.ExportConfig{Dir: *dir, Format: archive.Format(*format), DeleteAfterExport: *deleteAfter}
+			var err error
+			if cfg.Until, err = query.ParseTime(*until, now); err != nil {
+				return &exitError{ExitUsage, err}
+			}
+			if *since != "" {
+				if cfg.Since, err = query.ParseTime(*since, now); err != nil {
+					return &exitError{ExitUsage, err}
+				}
+			}
+			switch cfg.Format {
+			case archive.NDJSON, archive.Parquet:
+			default:
+				return usageErrorf("-format must be ndjson or parquet")
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+			m, err := archive.Export(ctx, db, cfg)
+			if err != nil {
+				return err
+			}
+
+			var rows int64
+			for _, f := range m.Files {
+				rows += f.Rows
+			}
+			var deleted int64
+			if *deleteAfter {
+				deleted = rows
+			}
+
+			if env.JSON() {
+				return env.WriteJSON(map[string]any{"dir": *dir, "rows": rows, "deleted": deleted, "manifest": m})
+			}
+			env.Printf("Export done: %d rows in %d files written to %s.\n", rows, len(m.Files), *dir)
+			if *deleteAfter {
+				env.Printf("Exported rows deleted: %d.\n", deleted)
+			}
+			return nil
+		}
+	},
+}
+
+var importCmd = &Command{
+	Name:        "import",
+	Summary:     "reload an export into a table",
+	Usage:       "-dir <dir> [-table logs] [-create-table] [-keep-ids] [-verify]",
+	Description: "Reloads an export written by the export command into a table, after checking its checksums.\nThe promoted columns of the config file are filled from the payloads.\nWith -verify, only checks the files against the manifest, without a database.",
+	Examples: []string{
+		"-dir ./archive/2025-01 -table logs_2025_01 -create-table",
+		"-dir ./archive/2025-01 -keep-ids          # restore purged rows into logs",
+		"-dir ./archive/2025-01 -verify",
+	},
+	Timeout: 30 * time.Minute,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		dir := fs.String("dir", "", "directory of an export written by the export command")
+		table := fs.String("table", "logs", "table the rows are inserted into")
+		createTable := fs.Bool("create-table", false, "create the table with the logs schema if it does not exist")
+		keepIDs := fs.Bool("keep-ids", false, "insert the exported ids instead of assigning new ones")
+		verifyOnly := fs.Bool("verify", false, "only check the files against the manifest checksums, without a database")
+
+		return func(ctx context.Context, env *Env) error {
+			if *dir == "" {
+				return usageErrorf("-dir is required")
+			}
+
+			if *verifyOnly {
+				m, err := archive.ReadManifest(*dir)
+				if err == nil {
+					err = archive.Verify(*dir, m)
+				}
+				if err != nil {
+					return err
+				}
+				if env.JSON() {
+					return env.WriteJSON(map[string]any{"dir": *dir, "files": len(m.Files), "verified": true})
+				}
+				env.Printf("Export verified: %d files match the manifest.\n", len(m.Files))
+				return nil
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+			n, err := archive.Import(ctx, db, archive.ImportConfig{Dir: *dir, Table: *table, CreateTable: *createTable, KeepIDs: *keepIDs, Columns: env.Columns})
+			if err != nil {
+				return fmt.Errorf("%w (%d rows imported)", err, n)
+			}
+
+			if env.JSON() {
+				return env.WriteJSON(map[string]any{"dir": *dir, "table": *table, "rows": n})
+			}
+			env.Printf("Import done: %d rows inserted into %q.\n", n, *table)
+			return nil
+		}
+	},
+}
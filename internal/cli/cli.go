@@ -0,0 +1,327 @@
+// Package cli implements the better-logs commands. They are served as
+// subcommands of the betterlogs command and as the standalone logs-* commands.
+//
+// Every command shares the -database-url, -config, -timeout and -output flags
+// and the exit codes below.
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ZiplEix/better-logs/pgcore"
+	_ "github.com/lib/pq"
+)
+
+// Exit codes of the commands.
+const (
+	ExitOK = 0
+
+	// ExitDatabase is returned when the database cannot be opened or reached.
+	ExitDatabase = 1
+
+	// ExitUsage is returned for invalid flags, arguments or configuration.
+	ExitUsage = 2
+
+	// ExitFailure is returned when the operation itself fails.
+	ExitFailure = 3
+)
+
+// Command describes a command.
+type Command struct {
+	// Name is the subcommand name, e.g. "retention".
+	Name string
+
+	// Summary is a one line description shown in the command list.
+	Summary string
+
+	// Usage is the synopsis of the command specific flags and arguments.
+	Usage string
+
+	// Description is printed in the command help.
+	Description string
+
+	// Examples are printed in the command help, one per line.
+	Examples []string
+
+	// Outputs are the accepted -output values, the first being the default.
+	// If nil, "text" and "json" are accepted.
+	Outputs []string
+
+	// Timeout is the default -timeout. If zero, the command runs until done
+	// or interrupted.
+	Timeout time.Duration
+
+	// Flags registers the command flags on fs and returns the function
+	// running the command once they are parsed.
+	Flags func(fs *flag.FlagSet) func(ctx context.Context, env *Env) error
+}
+
+// commands lists the subcommands of the betterlogs command, in help order.
+var commands = []*Command{
+	migrateCmd,
+	dropCmd,
+	retentionCmd,
+	tailCmd,
+	searchCmd,
+	traceCmd,
+	statsCmd,
+	indexesCmd,
+	exportCmd,
+	importCmd,
+}
+
+// findCommand returns the command called name, or nil.
+func findCommand(name string) *Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Env is the environment a command runs in.
+type Env struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Output is the -output value.
+	Output string
+
+	// Args are the arguments left after the flags.
+	Args []string
+
+	// Columns are the promoted columns of the config file.
+	Columns []pgcore.Column
+
+	dsn string
+	db  *sql.DB
+}
+
+// DatabaseURL returns the database URL from -database-url, DATABASE_URL or
+// the config file, in that order of precedence.
+func (e *Env) DatabaseURL() (string, error) {
+	if e.dsn == "" {
+		return "", usageErrorf("no database URL: set -database-url, DATABASE_URL or database_url in the config file")
+	}
+	return e.dsn, nil
+}
+
+// DB opens the database on first use and checks it can be reached within
+// ctx, so that -timeout and interrupts apply to the connection.
+func (e *Env) DB(ctx context.Context) (*sql.DB, error) {
+	if e.db != nil {
+		return e.db, nil
+	}
+	dsn, err := e.DatabaseURL()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, &exitError{ExitDatabase, fmt.Errorf("failed to open database: %w", err)}
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, &exitError{ExitDatabase, fmt.Errorf("failed to ping database: %w", err)}
+	}
+	e.db = db
+	return db, nil
+}
+
+// JSON reports whether -output json was requested.
+func (e *Env) JSON() bool { return e.Output == "json" }
+
+// WriteJSON writes v to Stdout as a JSON line.
+func (e *Env) WriteJSON(v any) error {
+	return json.NewEncoder(e.Stdout).Encode(v)
+}
+
+// Printf writes a text result to Stdout.
+func (e *Env) Printf(format string, args ...any) {
+	fmt.Fprintf(e.Stdout, format, args...)
+}
+
+// exitError is an error carrying the exit code of the command.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// usageErrorf returns an error exiting with ExitUsage.
+func usageErrorf(format string, args ...any) error {
+	return &exitError{ExitUsage, fmt.Errorf(format, args...)}
+}
+
+// Main runs the betterlogs command: args[0] is the program name and args[1]
+// the subcommand. It returns the exit code.
+func Main(args []string) int {
+	prog := filepath.Base(args[0])
+	if len(args) < 2 {
+		printCommands(os.Stderr, prog)
+		return ExitUsage
+	}
+
+	name := args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 2 && findCommand(args[2]) != nil {
+			return Run(findCommand(args[2]), prog+" "+args[2], []string{"-h"}, os.Stdout, os.Stderr)
+		}
+		printCommands(os.Stdout, prog)
+		return ExitOK
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n\n", name)
+		printCommands(os.Stderr, prog)
+		return ExitUsage
+	}
+	return Run(cmd, prog+" "+name, args[2:], os.Stdout, os.Stderr)
+}
+
+// MainCommand runs the command called name as a standalone program: args[0]
+// is the program name. It returns the exit code.
+func MainCommand(name string, args []string) int {
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", name)
+		return ExitUsage
+	}
+	return Run(cmd, filepath.Base(args[0]), args[1:], os.Stdout, os.Stderr)
+}
+
+// printCommands writes the command list.
+func printCommands(w io.Writer, prog string) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\n", prog)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.Name, c.Summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the flags of a command.\n", prog)
+	fmt.Fprintln(w, "The database URL is read from -database-url, DATABASE_URL or the database_url key of")
+	fmt.Fprintln(w, "the config file given by -config or BETTERLOGS_CONFIG.")
+}
+
+// Run parses args as the flags of cmd, runs it and returns the exit code.
+// Errors are written to stderr, as JSON with -output json.
+func Run(cmd *Command, prog string, args []string, stdout, stderr io.Writer) int {
+	outputs := cmd.Outputs
+	if outputs == nil {
+		outputs = []string{"text", "json"}
+	}
+
+	var (
+		dsn        string
+		configPath string
+		timeout    time.Duration
+		output     string
+	)
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&dsn, "database-url", "", "PostgreSQL connection URL (default: $DATABASE_URL, then database_url of the config file)")
+	fs.StringVar(&configPath, "config", "", "JSON config file (default: $BETTERLOGS_CONFIG)")
+	if cmd.Timeout > 0 {
+		fs.DurationVar(&timeout, "timeout", cmd.Timeout, "operation timeout (e.g. 30s, 2m)")
+	}
+	fs.StringVar(&output, "output", "", "output format: "+strings.Join(outputs, ", ")+" (default "+outputs[0]+")")
+	run := cmd.Flags(fs)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s [flags] %s\n\n", prog, cmd.Usage)
+		fmt.Fprintf(w, "%s\n\n", cmd.Description)
+		if len(cmd.Examples) > 0 {
+			fmt.Fprintln(w, "Examples:")
+			for _, ex := range cmd.Examples {
+				fmt.Fprintf(w, "  %s\n", ex)
+			}
+			fmt.Fprintln(w)
+		}
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	env := &Env{Stdin: os.Stdin, Stdout: stdout, Stderr: stderr, Args: fs.Args()}
+	err := func() error {
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			return &exitError{ExitUsage, err}
+		}
+		env.dsn = firstNonEmpty(dsn, os.Getenv("DATABASE_URL"), cfg.DatabaseURL)
+		env.Output = firstNonEmpty(output, cfg.Output, outputs[0])
+		env.Columns = cfg.Columns
+		if !slices.Contains(outputs, env.Output) {
+			if output == "" {
+				// A config file default the command does not support.
+				env.Output = outputs[0]
+			} else {
+				return usageErrorf("-output must be one of %s", strings.Join(outputs, ", "))
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return run(ctx, env)
+	}()
+	if env.db != nil {
+		env.db.Close()
+	}
+	if err == nil {
+		return ExitOK
+	}
+
+	code := ExitFailure
+	var ee *exitError
+	if errors.As(err, &ee) {
+		code = ee.code
+	}
+	if env.JSON() {
+		_ = json.NewEncoder(stderr).Encode(map[string]any{"error": err.Error(), "exit_code": code})
+	} else {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		if code == ExitUsage {
+			fmt.Fprintf(stderr, "Run '%s -h' for usage.\n", prog)
+		}
+	}
+	return code
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
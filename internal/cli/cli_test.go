@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCmd returns a command recording its environment and returning err.
+func testCmd(got **Env, err error) *Command {
+	return &Command{
+		Name:        "test",
+		Description: "Test command.",
+		Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+			return func(_ context.Context, env *Env) error {
+				*got = env
+				return err
+			}
+		},
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  error
+		want int
+	}{
+		{"ok", nil, nil, ExitOK},
+		{"help", []string{"-h"}, nil, ExitOK},
+		{"unknown flag", []string{"-nope"}, nil, ExitUsage},
+		{"bad output", []string{"-output", "xml"}, nil, ExitUsage},
+		{"usage error", nil, usageErrorf("bad"), ExitUsage},
+		{"database error", nil, &exitError{ExitDatabase, errors.New("down")}, ExitDatabase},
+		{"failure", nil, errors.New("boom"), ExitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var env *Env
+			var stdout, stderr bytes.Buffer
+			if code := Run(testCmd(&env, tt.err), "test", tt.args, &stdout, &stderr); code != tt.want {
+				t.Errorf("expected exit code %d, got %d (stderr: %s)", tt.want, code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRun_JSONErrors(t *testing.T) {
+	var env *Env
+	var stdout, stderr bytes.Buffer
+	code := Run(testCmd(&env, errors.New("boom")), "test", []string{"-output", "json"}, &stdout, &stderr)
+	if code != ExitFailure {
+		t.Fatalf("expected exit code %d, got %d", ExitFailure, code)
+	}
+
+	var out struct {
+		Error    string `json:"error"`
+		ExitCode int    `json:"exit_code"`
+	}
+	if err := json.Unmarshal(stderr.Bytes(), &out); err != nil {
+		t.Fatalf("expected a JSON error, got %q", stderr.String())
+	}
+	if out.Error != "boom" || out.ExitCode != ExitFailure {
+		t.Errorf("unexpected error body: %+v", out)
+	}
+}
+
+func TestRun_DatabaseURLPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"database_url":"postgres://file","output":"json"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{"config file", "", []string{"-config", path}, "postgres://file"},
+		{"environment", "postgres://env", []string{"-config", path}, "postgres://env"},
+		{"flag", "postgres://env", []string{"-config", path, "-database-url", "postgres://flag"}, "postgres://flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", tt.env)
+
+			var env *Env
+			var stdout, stderr bytes.Buffer
+			if code := Run(testCmd(&env, nil), "test", tt.args, &stdout, &stderr); code != ExitOK {
+				t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+			}
+			got, err := env.DatabaseURL()
+			if err != nil || got != tt.want {
+				t.Errorf("expected %q, got %q (%v)", tt.want, got, err)
+			}
+			if !env.JSON() {
+				t.Errorf("expected the config file output default")
+			}
+		})
+	}
+}
+
+func TestEnv_DBRequiresURL(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+
+	var stdout, stderr bytes.Buffer
+	cmd := &Command{
+		Name: "test",
+		Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+			return func(ctx context.Context, env *Env) error {
+				_, err := env.DB(ctx)
+				return err
+			}
+		},
+	}
+	if code := Run(cmd, "test", nil, &stdout, &stderr); code != ExitUsage {
+		t.Errorf("expected exit code %d, got %d", ExitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "DATABASE_URL") {
+		t.Errorf("expected the error to mention DATABASE_URL, got %q", stderr.String())
+	}
+}
+
+func TestEnv_DBPingHonorsContext(t *testing.T) {
+	// A server accepting connections without ever answering: only the
+	// context can stop the ping.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	env := &Env{dsn: "postgres://u@" + ln.Addr().String() + "/db?sslmode=disable"}
+	done := make(chan error, 1)
+	go func() {
+		_, err := env.DB(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		var ee *exitError
+		if !errors.As(err, &ee) || ee.code != ExitDatabase {
+			t.Errorf("expected a database error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected DB to return once the context is done")
+	}
+}
+
+func TestCommands_HaveHelp(t *testing.T) {
+	for _, c := range commands {
+		var stdout, stderr bytes.Buffer
+		if code := Run(c, c.Name, []string{"-h"}, &stdout, &stderr); code != ExitOK {
+			t.Errorf("%s: expected -h to exit with %d, got %d", c.Name, ExitOK, code)
+		}
+		if !strings.Contains(stderr.String(), "-database-url") {
+			t.Errorf("%s: expected the usage to list the shared flags", c.Name)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"database_url":"postgres://file","columns":[{"name":"user_id","index":true}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("BETTERLOGS_CONFIG", "")
+	cfg, err := LoadConfig("")
+	if err != nil || !reflect.DeepEqual(cfg, Config{}) {
+		t.Errorf("expected an empty config without a file, got %+v (%v)", cfg, err)
+	}
+
+	t.Setenv("BETTERLOGS_CONFIG", path)
+	cfg, err = LoadConfig("")
+	if err != nil || cfg.DatabaseURL != "postgres://file" {
+		t.Errorf("expected the BETTERLOGS_CONFIG file, got %+v (%v)", cfg, err)
+	}
+	if len(cfg.Columns) != 1 || cfg.Columns[0].Name != "user_id" || !cfg.Columns[0].Index {
+		t.Errorf("expected the user_id column, got %+v", cfg.Columns)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"database":"typo"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(bad); err == nil {
+		t.Errorf("expected an error for an unknown key")
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
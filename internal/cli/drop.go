@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ZiplEix/better-logs/archive"
+)
+
+const dropLogsTableSQL = `DROP TABLE IF EXISTS logs;`
+
+// dropTargetSQL reads the connected database and whether the logs table
+// exists.
+const dropTargetSQL = `
+SELECT current_database(), to_regclass('logs') IS NOT NULL`
+
+// dropRowsSQL reads the row count and newest entry of the logs table.
+const dropRowsSQL = `SELECT count(*), max(ts) FROM logs`
+
+// dropTarget describes the table a drop would destroy.
+type dropTarget struct {
+	Host     string     `json:"host"`
+	Database string     `json:"database"`
+	Table    string     `json:"table"`
+	Exists   bool       `json:"exists"`
+	Rows     int64      `json:"rows"`
+	Newest   *time.Time `json:"newest"`
+}
+
+func (t dropTarget) String() string {
+	s := fmt.Sprintf("table %q on %s/%s (%d rows", t.Table, t.Host, t.Database, t.Rows)
+	if t.Newest != nil {
+		s += ", newest " + t.Newest.Local().Format(time.RFC3339)
+	}
+	return s + ")"
+}
+
+var dropCmd = &Command{
+	Name:    "drop",
+	Summary: "drop the logs table",
+	Usage:   "[-yes -confirm-database <name>] [-force] [-export-dir <dir>]",
+	Description: `Drops the 'logs' table after showing the target host, database and row count.
+
+The drop must be confirmed by typing the database name, or with -yes and
+-confirm-database set to the name of the connected database. It is refused
+when the table holds rows newer than -recent, unless -force is set.`,
+	Examples: []string{
+		"# interactive: shows the target and asks for the database name",
+		"-yes -confirm-database logs_dev",
+		"-yes -confirm-database logs_prod -force -export-dir ./archive/before-drop",
+	},
+	// Leaves time to type the confirmation.
+	Timeout: 2 * time.Minute,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		yes := fs.Bool("yes", false, "do not ask for confirmation (requires -confirm-database)")
+		confirmDB := fs.String("confirm-database", "", "name of the database the table is dropped from, checked against the connected one")
+		recent := fs.Duration("recent", 24*time.Hour, "refuse to drop a table holding rows newer than this (0 disables the check)")
+		force := fs.Bool("force", false, "drop even if the table holds recent rows")
+		exportDir := fs.String("export-dir", "", "export every row to this directory before dropping (see the export command; raise -timeout)")
+		exportFormat := fs.String("export-format", "ndjson", "format of -export-dir: ndjson or parquet")
+
+		return func(ctx context.Context, env *Env) error {
+			if *yes && *confirmDB == "" {
+				return usageErrorf("-yes requires -confirm-database")
+			}
+			format := archive.Format(*exportFormat)
+			if format != archive.NDJSON && format != archive.Parquet {
+				return usageErrorf("-export-format must be ndjson or parquet")
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+			dsn, _ := env.DatabaseURL()
+			target, err := inspectDropTarget(ctx, db, dsn)
+			if err != nil {
+				return err
+			}
+
+			if !target.Exists {
+				if env.JSON() {
+					return env.WriteJSON(map[string]any{"target": target, "dropped": false})
+				}
+				env.Printf("Table %q does not exist on %s/%s, nothing to drop.\n", target.Table, target.Host, target.Database)
+				return nil
+			}
+			if !env.JSON() {
+				fmt.Fprintf(env.Stderr, "About to drop %s.\n", target)
+			}
+
+			if *recent > 0 && target.Newest != nil && time.Since(*target.Newest) < *recent && !*force {
+				return fmt.Errorf("refusing to drop: the table holds rows newer than %s (newest %s); use -force to drop anyway",
+					*recent, target.Newest.Local().Format(time.RFC3339))
+			}
+
+			switch {
+			case *yes:
+				if *confirmDB != target.Database {
+					return usageErrorf("-confirm-database %q does not match the connected database %q", *confirmDB, target.Database)
+				}
+			case isInteractive(env.Stdin):
+				if err := confirm(env.Stdin, env.Stderr, target.Database); err != nil {
+					return err
+				}
+			default:
+				return usageErrorf("not running interactively: pass -yes and -confirm-database %s to drop", target.Database)
+			}
+
+			out := map[string]any{"target": target}
+			m, err := dropLogsTable(ctx, db, *exportDir, format)
+			if m != nil {
+				out["export"] = m
+				if !env.JSON() {
+					env.Printf("Exported %d files to %s.\n", len(m.Files), *exportDir)
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			if env.JSON() {
+				out["dropped"] = true
+				return env.WriteJSON(out)
+			}
+			env.Printf("Dropped %s.\n", target)
+			return nil
+		}
+	},
+}
+
+// dropLockSQL blocks writers until the table is dropped, so that the rows
+// exported before the drop are all the rows of the table.
+const dropLockSQL = `LOCK TABLE logs IN ACCESS EXCLUSIVE MODE`
+
+// dropExportRangeSQL reads the row count and newest entry of the locked table.
+const dropExportRangeSQL = `SELECT count(*), COALESCE(max(ts), now()) FROM logs`
+
+// dropLogsTable drops the logs table. With a non-empty exportDir, every row
+// is first exported there in the transaction dropping the table, which holds
+// an exclusive lock on it: rows inserted meanwhile wait for the drop and fail,
+// none is dropped without being archived. The returned manifest is nil
+// without export.
+func dropLogsTable(ctx context.Context, db *sql.DB, exportDir string, format archive.Format) (*archive.Manifest, error) {
+	if exportDir == "" {
+		if _, err := db.ExecContext(ctx, dropLogsTableSQL); err != nil {
+			return nil, fmt.Errorf("failed to drop logs table: %w", err)
+		}
+		return nil, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, dropLockSQL); err != nil {
+		return nil, fmt.Errorf("failed to lock logs table: %w", err)
+	}
+	var (
+		rows   int64
+		newest time.Time
+	)
+	if err := tx.QueryRowContext(ctx, dropExportRangeSQL).Scan(&rows, &newest); err != nil {
+		return nil, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	// ts has a microsecond precision: the newest row is before Until.
+	m, err := archive.ExportTx(ctx, tx, archive.ExportConfig{
+		Dir:    exportDir,
+		Until:  newest.Add(time.Microsecond),
+		Format: format,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("export before drop failed, table kept: %w", err)
+	}
+	var exported int64
+	for _, f := range m.Files {
+		exported += f.Rows
+	}
+	if exported != rows {
+		return &m, fmt.Errorf("exported %d of %d rows, table kept", exported, rows)
+	}
+
+	if _, err := tx.ExecContext(ctx, dropLogsTableSQL); err != nil {
+		return &m, fmt.Errorf("failed to drop logs table: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return &m, fmt.Errorf("failed to drop logs table: %w", err)
+	}
+	return &m, nil
+}
+
+// inspectDropTarget reads the database name, row count and newest entry of
+// the logs table.
+func inspectDropTarget(ctx context.Context, db *sql.DB, dsn string) (dropTarget, error) {
+	t := dropTarget{Host: dsnHost(dsn), Table: "logs"}
+	if err := db.QueryRowContext(ctx, dropTargetSQL).Scan(&t.Database, &t.Exists); err != nil {
+		return t, fmt.Errorf("failed to inspect the database: %w", err)
+	}
+	if !t.Exists {
+		return t, nil
+	}
+
+	var newest sql.NullTime
+	if err := db.QueryRowContext(ctx, dropRowsSQL).Scan(&t.Rows, &newest); err != nil {
+		return t, fmt.Errorf("failed to count rows: %w", err)
+	}
+	if newest.Valid {
+		t.Newest = &newest.Time
+	}
+	return t, nil
+}
+
+// dsnHost returns the host and port of a URL or key=value connection string.
+func dsnHost(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		if u.Host != "" {
+			return u.Host
+		}
+		return "localhost"
+	}
+
+	host, port := "localhost", ""
+	for _, kv := range strings.Fields(dsn) {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "host":
+			host = strings.Trim(v, "'")
+		case "port":
+			port = strings.Trim(v, "'")
+		}
+	}
+	if port != "" {
+		return host + ":" + port
+	}
+	return host
+}
+
+// isInteractive reports whether r is a terminal.
+func isInteractive(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// errNotConfirmed is returned when the typed database name does not match.
+var errNotConfirmed = errors.New("confirmation does not match the database name, table kept")
+
+// confirm asks for the database name on out and reads it from in.
+func confirm(in io.Reader, out io.Writer, database string) error {
+	fmt.Fprintf(out, "Type the database name (%s) to confirm: ", database)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return errNotConfirmed
+	}
+	if strings.TrimSpace(line) != database {
+		return errNotConfirmed
+	}
+	return nil
+}
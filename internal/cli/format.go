@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ZiplEix/better-logs/query"
+)
+
+// ANSI color codes.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiGray   = "\x1b[90m"
+)
+
+// useColor resolves a -color flag value for output written to w.
+func useColor(mode string, w io.Writer, env *Env) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		f, ok := w.(*os.File)
+		return ok && !env.JSON() && isTerminal(f) && os.Getenv("NO_COLOR") == "", nil
+	}
+	return false, usageErrorf("-color must be auto, always or never")
+}
+
+// paint wraps s in the given color when on is true.
+func paint(on bool, code, s string) string {
+	if !on {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// escapeControl escapes the control characters of s but tabs, so that a
+// logged value cannot move the cursor or clear the terminal it is printed to.
+func escapeControl(s string) string {
+	if strings.IndexFunc(s, isEscaped) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if isEscaped(r) {
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isEscaped(r rune) bool {
+	return r != '\t' && unicode.IsControl(r)
+}
+
+// isTerminal reports whether f is a character device (an interactive terminal).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// isError reports whether a zap level name is error or above.
+func isError(level string) bool {
+	switch level {
+	case "error", "dpanic", "panic", "fatal":
+		return true
+	}
+	return false
+}
+
+// formatValue formats a decoded JSON value for the human output: strings are
+// quoted only when needed, objects and arrays are printed as compact JSON.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" || strings.ContainsAny(v, " \"=") || strings.IndexFunc(v, unicode.IsControl) >= 0 {
+			return strconv.Quote(v)
+		}
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// cellValue formats a decoded JSON value as a table or CSV cell: strings as
+// is, other values as compact JSON, missing values as "".
+func cellValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// lookup returns the value at a dot separated path of a decoded payload (see
+// query.SplitPath).
+func lookup(fields map[string]any, path string) any {
+	keys, err := query.SplitPath(path)
+	if err != nil {
+		return nil
+	}
+	var v any = fields
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// multiFlag collects a repeatable string flag.
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ", ") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
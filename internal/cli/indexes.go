@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	betterlogs "github.com/ZiplEix/better-logs"
+)
+
+var indexesCmd = &Command{
+	Name:    "indexes",
+	Summary: "list, create or drop the optional indexes of the logs table",
+	Usage:   "[-raw] [-brin] [-field path]... [-drop name]... [-concurrently]",
+	Description: `Without flags, lists the indexes of the logs table with their size and validity.
+
+-raw creates a GIN jsonb_path_ops index on raw, serving the field equality filters.
+-brin creates a BRIN index on ts, a small index for append-only tables.
+-field creates a B-tree index on the text of a payload field, e.g. service,
+serving the equality filters on its string values.
+-drop drops an index by name.
+
+Use -concurrently on a live table: indexes are built without blocking writes,
+one statement at a time. An index left invalid by a failed concurrent build is
+rebuilt on the next run.`,
+	Examples: []string{
+		"# without flags: list the indexes",
+		"-raw -field service -field user.id -concurrently",
+		"-brin -concurrently -output json",
+	},
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		raw := fs.Bool("raw", false, "create the GIN jsonb_path_ops index on raw")
+		brin := fs.Bool("brin", false, "create the BRIN index on ts")
+		var fields, drops multiFlag
+		fs.Var(&fields, "field", "create an expression index on the payload field at this dot separated path (repeatable)")
+		fs.Var(&drops, "drop", "drop the index with this name (repeatable)")
+		concurrently := fs.Bool("concurrently", false, "create and drop with CONCURRENTLY, without blocking writes")
+
+		return func(ctx context.Context, env *Env) error {
+			var create []betterlogs.Index
+			if *raw {
+				create = append(create, betterlogs.RawIndex())
+			}
+			if *brin {
+				create = append(create, betterlogs.TSBRINIndex())
+			}
+			for _, f := range fields {
+				ix, err := betterlogs.FieldIndex(f)
+				if err != nil {
+					return usageErrorf("-field %q: invalid path", f)
+				}
+				create = append(create, ix)
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+			opts := betterlogs.IndexOptions{Concurrently: *concurrently}
+			if err := betterlogs.CreateIndexes(ctx, db, opts, create...); err != nil {
+				return err
+			}
+			if err := betterlogs.DropIndexes(ctx, db, opts, drops...); err != nil {
+				return err
+			}
+
+			list, err := betterlogs.ListIndexes(ctx, db)
+			if err != nil {
+				return err
+			}
+			if env.JSON() {
+				return env.WriteJSON(map[string]any{"created": create, "dropped": drops, "indexes": list})
+			}
+
+			for _, ix := range create {
+				env.Printf("Index %s created or already exists.\n", ix.Name)
+			}
+			for _, name := range drops {
+				env.Printf("Index %s dropped.\n", name)
+			}
+			if len(create)+len(drops) > 0 {
+				env.Printf("\n")
+			}
+
+			tw := tabwriter.NewWriter(env.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "INDEX\tSIZE\tVALID\tDEFINITION\n")
+			for _, ix := range list {
+				fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", ix.Name, formatBytes(ix.Bytes), ix.Valid, ix.Definition)
+			}
+			return tw.Flush()
+		}
+	},
+}
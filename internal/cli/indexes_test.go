@@ -0,0 +1,13 @@
+package cli
+
+import (
+	"io"
+	"testing"
+)
+
+func TestIndexes_InvalidFieldIsUsageError(t *testing.T) {
+	code := Run(indexesCmd, "betterlogs indexes", []string{"-database-url", "postgres://unused", "-field", "user."}, io.Discard, io.Discard)
+	if code != ExitUsage {
+		t.Errorf("expected exit code %d, got %d", ExitUsage, code)
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// We rely on Postgres interval parsing by concatenating the string and casting:
+// DELETE FROM logs WHERE ts < NOW() - ($1 || ”)::interval;
+const retentionSQL = `
+DELETE FROM logs
+WHERE ts < NOW() - ($1 || '' )::interval;
+`
+
+const retentionCountSQL = `
+SELECT count(*) FROM logs
+WHERE ts < NOW() - ($1 || '' )::interval;
+`
+
+var retentionCmd = &Command{
+	Name:        "retention",
+	Summary:     "delete logs older than an interval",
+	Usage:       "-older-than <interval> [-dry-run]",
+	Description: "Deletes logs older than the specified interval.",
+	Examples: []string{
+		"-older-than '168h'       # 7 days",
+		"-older-than '7 days'",
+		"-older-than '1 month' -dry-run",
+	},
+	Timeout: 30 * time.Second,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		olderThan := fs.String("older-than", "", "interval for log retention (e.g. '168h', '7 days', '1 month')")
+		dryRun := fs.Bool("dry-run", false, "if true, show how many rows would be deleted without actually deleting")
+
+		return func(ctx context.Context, env *Env) error {
+			if *olderThan == "" {
+				return usageErrorf("-older-than is required")
+			}
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+
+			var n int64
+			if *dryRun {
+				if err := db.QueryRowContext(ctx, retentionCountSQL, *olderThan).Scan(&n); err != nil {
+					return fmt.Errorf("dry-run count query failed: %w", err)
+				}
+			} else {
+				res, err := db.ExecContext(ctx, retentionSQL, *olderThan)
+				if err != nil {
+					return fmt.Errorf("failed to apply retention: %w", err)
+				}
+				if n, err = res.RowsAffected(); err != nil {
+					return fmt.Errorf("could not read affected rows: %w", err)
+				}
+			}
+
+			switch {
+			case env.JSON():
+				return env.WriteJSON(map[string]any{"older_than": *olderThan, "dry_run": *dryRun, "deleted": n})
+			case *dryRun:
+				env.Printf("Dry run: %d rows would be deleted (older than %q).\n", n, *olderThan)
+			default:
+				env.Printf("Retention applied: %d rows deleted (older than %q).\n", n, *olderThan)
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	betterlogs "github.com/ZiplEix/better-logs"
+	"github.com/ZiplEix/better-logs/pgcore"
+)
+
+var migrateCmd = &Command{
+	Name:    "migrate",
+	Summary: "create the logs table and indexes",
+	Usage:   "[-column name[:type]]... [-concurrently]",
+	Description: `Creates the 'logs' table and indexes if they do not exist.
+
+Promoted columns, filled by the logger from the log field of the same name
+(see Config.PG.Columns), are added with an index. They are read from the
+"columns" list of the config file and from -column.
+
+Building an index blocks writes to the table until it is done. Use
+-concurrently when adding columns to a live table: their indexes are built
+without blocking writes, one statement at a time. An index left invalid by a
+failed concurrent build is rebuilt on the next run.`,
+	Examples: []string{
+		"-column user_id -column tenant_id -column status:integer",
+		"-config betterlogs.json -concurrently",
+	},
+	// Index builds on a large table take a while.
+	Timeout: 30 * time.Minute,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		var columns multiFlag
+		fs.Var(&columns, "column", "add an indexed promoted column, as name or name:type (repeatable)")
+		concurrently := fs.Bool("concurrently", false, "build the column indexes with CONCURRENTLY, without blocking writes")
+
+		return func(ctx context.Context, env *Env) error {
+			cols := append([]pgcore.Column(nil), env.Columns...)
+			for _, s := range columns {
+				c, err := parseColumn(s)
+				if err != nil {
+					return err
+				}
+				cols = append(cols, c)
+			}
+
+			// The columns are added first, their indexes are built afterwards
+			// by CreateIndexes, which can do it concurrently.
+			var (
+				plain   = make([]pgcore.Column, len(cols))
+				indexes []betterlogs.Index
+			)
+			for i, c := range cols {
+				if c.Index {
+					indexes = append(indexes, betterlogs.ColumnIndex(c))
+				}
+				c.Index = false
+				plain[i] = c
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+			if err := betterlogs.EnsureLogsTable(ctx, db, plain...); err != nil {
+				return fmt.Errorf("failed to ensure logs table: %w", err)
+			}
+			opts := betterlogs.IndexOptions{Concurrently: *concurrently}
+			if err := betterlogs.CreateIndexes(ctx, db, opts, indexes...); err != nil {
+				return err
+			}
+
+			if env.JSON() {
+				return env.WriteJSON(map[string]any{"table": "logs", "migrated": true, "columns": cols})
+			}
+			env.Printf("Logs table created or already exists.\n")
+			for _, c := range cols {
+				env.Printf("Column %s %s ready.\n", c.Name, c.SQLType())
+			}
+			return nil
+		}
+	},
+}
+
+// parseColumn parses a -column value, name or name:type, into an indexed
+// column.
+func parseColumn(s string) (pgcore.Column, error) {
+	name, typ, _ := strings.Cut(s, ":")
+	c := pgcore.Column{Name: name, Type: typ, Index: true}
+	if err := c.Validate(); err != nil {
+		return c, usageErrorf("-column %q: %v", s, strings.TrimPrefix(err.Error(), "pgcore: "))
+	}
+	return c, nil
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"io"
+	"testing"
+)
+
+func TestParseColumn(t *testing.T) {
+	c, err := parseColumn("status:integer")
+	if err != nil || c.Name != "status" || c.SQLType() != "integer" || !c.Index {
+		t.Errorf("parseColumn(status:integer) = %+v, %v", c, err)
+	}
+	c, err = parseColumn("user_id")
+	if err != nil || c.Name != "user_id" || c.SQLType() != "text" {
+		t.Errorf("parseColumn(user_id) = %+v, %v", c, err)
+	}
+	for _, s := range []string{"", "User", "raw", "data:jsonb"} {
+		if _, err := parseColumn(s); err == nil {
+			t.Errorf("parseColumn(%q): expected an error", s)
+		}
+	}
+}
+
+func TestMigrate_InvalidColumnIsUsageError(t *testing.T) {
+	code := Run(migrateCmd, "betterlogs migrate", []string{"-database-url", "postgres://unused", "-column", "req_id"}, io.Discard, io.Discard)
+	if code != ExitUsage {
+		t.Errorf("expected exit code %d, got %d", ExitUsage, code)
+	}
+}
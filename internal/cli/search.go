@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ZiplEix/better-logs/query"
+)
+
+var searchCmd = &Command{
+	Name:    "search",
+	Summary: "search logs with a compact query language",
+	Usage:   "<expression>",
+	Description: `Searches the logs table. The expression is a list of terms, all of which must match.
+
+Terms:
+  path=value, !=, <, <=, >, >=   compare a JSON path (e.g. user.id=42)
+  path~text                      the value contains text
+  level>=warn                    compare levels by severity
+  since=2h, until=<RFC 3339>     time range
+  word                           full-text search
+
+With -output json, entries are printed as raw JSON lines.`,
+	Examples: []string{
+		`'level>=warn service=auth status>=500 since=2h msg~"timeout"'`,
+		`-count 'since=1h level=error'`,
+		`-group-by status 'since=24h path=/login'`,
+	},
+	Outputs: []string{"table", "json", "csv", "text"},
+	Timeout: 30 * time.Second,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		limit := fs.Int("limit", 100, "maximum number of entries (or groups) to print")
+		count := fs.Bool("count", false, "print the number of matching entries instead of the entries")
+		groupBy := fs.String("group-by", "", "count matching entries per value of this JSON path (e.g. 'status')")
+		columns := fs.String("columns", "", "extra JSON paths printed as table/csv columns (comma separated)")
+		asc := fs.Bool("asc", false, "oldest entries first")
+		cursor := fs.String("cursor", "", "continue after the cursor printed by a previous search")
+
+		return func(ctx context.Context, env *Env) error {
+			output := env.Output
+			if output == "text" {
+				output = "table"
+			}
+
+			q, err := query.Parse(strings.Join(env.Args, " "), time.Now())
+			if err != nil {
+				return &exitError{ExitUsage, err}
+			}
+			q.Limit(*limit)
+			if *asc {
+				q.Ascending()
+			}
+			if *cursor != "" {
+				c, err := query.ParseCursor(*cursor)
+				if err != nil {
+					return &exitError{ExitUsage, err}
+				}
+				q.After(c)
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case *count:
+				n, err := q.Count(ctx, db)
+				if err != nil {
+					return err
+				}
+				printCount(env.Stdout, output, n)
+
+			case *groupBy != "":
+				groups, err := q.GroupBy(ctx, db, *groupBy)
+				if err != nil {
+					return err
+				}
+				printGroups(env.Stdout, output, *groupBy, groups)
+
+			default:
+				page, err := q.Run(ctx, db)
+				if err != nil {
+					return err
+				}
+				printEntries(env.Stdout, output, splitList(*columns), page.Entries)
+				if page.Next != nil {
+					fmt.Fprintf(env.Stderr, "more results: -cursor %s\n", page.Next)
+				}
+			}
+			return nil
+		}
+	},
+}
+
+// printCount writes the number of matching entries.
+func printCount(w io.Writer, output string, n int64) {
+	switch output {
+	case "json":
+		_ = json.NewEncoder(w).Encode(map[string]int64{"count": n})
+	case "csv":
+		fmt.Fprintf(w, "count\n%d\n", n)
+	default:
+		fmt.Fprintln(w, n)
+	}
+}
+
+// printGroups writes the groups returned by GroupBy.
+func printGroups(w io.Writer, output, path string, groups []query.Group) {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		for _, g := range groups {
+			_ = enc.Encode(g)
+		}
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{path, "count"})
+		for _, g := range groups {
+			_ = cw.Write([]string{g.Value, strconv.FormatInt(g.Count, 10)})
+		}
+		cw.Flush()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\tCOUNT\n", strings.ToUpper(path))
+		for _, g := range groups {
+			v := g.Value
+			if g.Null {
+				v = "(none)"
+			}
+			fmt.Fprintf(tw, "%s\t%d\n", v, g.Count)
+		}
+		_ = tw.Flush()
+	}
+}
+
+// printEntries writes entries with the given extra columns.
+func printEntries(w io.Writer, output string, columns []string, entries []query.LogEntry) {
+	switch output {
+	case "json":
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\n", e.Raw)
+		}
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write(append(append([]string{"id", "ts", "level", "service", "msg", "req_id", "trace_id"}, columns...), "raw"))
+		for _, e := range entries {
+			rec := []string{strconv.FormatInt(e.ID, 10), e.TS.UTC().Format(time.RFC3339Nano), e.Level, e.Service, e.Message, e.ReqID, e.TraceID}
+			for _, c := range columns {
+				rec = append(rec, cellValue(lookup(e.Fields, c)))
+			}
+			_ = cw.Write(append(rec, string(e.Raw)))
+		}
+		cw.Flush()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		header := append([]string{"TS", "LEVEL", "SERVICE", "MSG", "REQ_ID"}, columns...)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, e := range entries {
+			row := []string{e.LoggedAt().Local().Format("2006-01-02 15:04:05.000"), e.Level, e.Service, e.Message, e.ReqID}
+			for _, c := range columns {
+				row = append(row, cellValue(lookup(e.Fields, c)))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		_ = tw.Flush()
+	}
+}
+
+// splitList parses a comma separated flag, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
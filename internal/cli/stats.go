@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ZiplEix/better-logs/stats"
+)
+
+var statsCmd = &Command{
+	Name:    "stats",
+	Summary: "report the size and content of the logs table",
+	Usage:   "[-window 720h] [-top 10]",
+	Description: `Reports the rows and on-disk size (table, indexes, TOAST) of the logs table, its
+oldest and newest entry, the rows per day, service and level and the top messages
+over -window, and the index sizes, scans and bloat estimates.
+With -output json, the report is printed as a single JSON object for monitoring.`,
+	Examples: []string{
+		"-window 168h -top 5",
+		"-output json | jq .size.total_bytes",
+	},
+	Timeout: 2 * time.Minute,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		def := stats.DefaultConfig()
+		window := fs.Duration("window", def.Window, "period covered by the per day, service, level and message counts")
+		top := fs.Int("top", def.Top, "number of services and messages reported")
+
+		return func(ctx context.Context, env *Env) error {
+			if *window <= 0 || *top <= 0 {
+				return usageErrorf("-window and -top must be positive")
+			}
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+
+			r, err := stats.Collect(ctx, db, stats.Config{Window: *window, Top: *top})
+			if err != nil {
+				return err
+			}
+			if env.JSON() {
+				return env.WriteJSON(r)
+			}
+			return printReport(env.Stdout, r)
+		}
+	},
+}
+
+// printReport writes r in a human readable form.
+func printReport(w io.Writer, r stats.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "rows\t%d\n", r.Rows)
+	fmt.Fprintf(tw, "size\t%s (table %s, indexes %s, toast %s)\n", formatBytes(r.Size.Total),
+		formatBytes(r.Size.Table), formatBytes(r.Size.Indexes), formatBytes(r.Size.TOAST))
+	if r.Oldest != nil {
+		fmt.Fprintf(tw, "oldest\t%s\n", r.Oldest.Local().Format(time.RFC3339))
+		fmt.Fprintf(tw, "newest\t%s\n", r.Newest.Local().Format(time.RFC3339))
+	}
+
+	since := r.Since.Local().Format(time.DateOnly)
+	sections := []struct {
+		title  string
+		counts []stats.Count
+	}{
+		{"ROWS PER DAY", r.PerDay},
+		{"SERVICES since " + since, r.PerService},
+		{"LEVELS since " + since, r.PerLevel},
+		{"TOP MESSAGES since " + since, r.TopMessages},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(tw, "\n%s\t\n", s.title)
+		for _, c := range s.counts {
+			v := c.Value
+			if c.Null {
+				v = "(none)"
+			}
+			fmt.Fprintf(tw, "  %s\t%d\n", v, c.Count)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nINDEXES\tSIZE\tSCANS\tEST. BLOAT\n")
+	for _, idx := range r.Indexes {
+		bloat := "-"
+		if idx.BloatBytes != nil {
+			bloat = fmt.Sprintf("%s (%.0f%%)", formatBytes(*idx.BloatBytes), *idx.BloatRatio*100)
+		}
+		fmt.Fprintf(tw, "  %s (%s)\t%s\t%d\t%s\n", idx.Name, idx.Method, formatBytes(idx.Bytes), idx.Scans, bloat)
+	}
+	return tw.Flush()
+}
+
+// formatBytes formats a size with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZiplEix/better-logs/stats"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1536:          "1.5 KiB",
+		5 << 20:       "5.0 MiB",
+		3<<30 + 1<<29: "3.5 GiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	oldest := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bloat, ratio := int64(1<<20), 0.25
+	r := stats.Report{
+		Rows:        3,
+		Size:        stats.Size{Table: 8192, Indexes: 4 << 20, Total: 5 << 20},
+		Oldest:      &oldest,
+		Newest:      &oldest,
+		Since:       oldest,
+		PerService:  []stats.Count{{Value: "api", Count: 2}, {Null: true, Count: 1}},
+		TopMessages: []stats.Count{{Value: "http_request", Count: 2}},
+		Indexes: []stats.Index{
+			{Name: "logs_pkey", Method: "btree", Bytes: 4 << 20, Scans: 7, BloatBytes: &bloat, BloatRatio: &ratio},
+			{Name: "idx_logs_raw", Method: "gin", Bytes: 8192},
+		},
+	}
+
+	var b bytes.Buffer
+	if err := printReport(&b, r); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"5.0 MiB", "(none)", "http_request", "1.0 MiB (25%)", "idx_logs_raw (gin)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected the report to contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZiplEix/better-logs/query"
+	"github.com/lib/pq"
+	"go.uber.org/zap/zapcore"
+)
+
+// notifyTriggerSQL installs a statement level trigger notifying channel %[1]s
+// after each insert batch into the logs table.
+const notifyTriggerSQL = `
+CREATE OR REPLACE FUNCTION better_logs_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify(%[1]s, '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS better_logs_notify ON logs;
+CREATE TRIGGER better_logs_notify AFTER INSERT ON logs
+    FOR EACH STATEMENT EXECUTE FUNCTION better_logs_notify();
+`
+
+// tailBatchSize is the number of rows fetched per query while catching up.
+const tailBatchSize = 500
+
+var tailCmd = &Command{
+	Name:        "tail",
+	Summary:     "follow new logs live",
+	Usage:       "[filters] [-n 10] [-listen]",
+	Description: "Follows the logs table, printing new entries as they are inserted.\nWith -output json, entries are printed as raw JSON lines.",
+	Examples: []string{
+		"-service api -min-level warn",
+		"-field 'status>=500' -output json",
+	},
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		var fields multiFlag
+		service := fs.String("service", "", "only show logs of this service")
+		levels := fs.String("level", "", "only show these levels (comma separated, e.g. 'warn,error')")
+		minLevel := fs.String("min-level", "", "only show logs at this level or above (e.g. 'warn')")
+		reqID := fs.String("req-id", "", "only show logs of this request ID")
+		traceID := fs.String("trace-id", "", "only show logs of this trace ID")
+		fs.Var(&fields, "field", "JSON field condition such as 'user.id=42' or 'status>=500' (repeatable)")
+		lines := fs.Int("n", 10, "number of existing lines to show before following")
+		interval := fs.Duration("interval", time.Second, "polling interval")
+		window := fs.Duration("late-window", query.DefaultFollowWindow, "how long ids reserved by batches not yet committed are waited for")
+		listen := fs.Bool("listen", false, "also poll as soon as a LISTEN/NOTIFY notification arrives (see -install-trigger)")
+		channel := fs.String("channel", "better_logs", "notification channel used by -listen and -install-trigger")
+		installTrigger := fs.Bool("install-trigger", false, "install the trigger notifying -channel on inserts, then exit")
+		jsonOut := fs.Bool("json", false, "same as -output json")
+		color := fs.String("color", "auto", "colorize output: auto, always or never")
+
+		return func(ctx context.Context, env *Env) error {
+			if *jsonOut {
+				env.Output = "json"
+			}
+			if *interval <= 0 {
+				return usageErrorf("-interval must be positive")
+			}
+			filter, err := buildFilter(*service, *levels, *minLevel, *reqID, *traceID, fields)
+			if err != nil {
+				return &exitError{ExitUsage, err}
+			}
+			colored, err := useColor(*color, env.Stdout, env)
+			if err != nil {
+				return err
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+
+			if *installTrigger {
+				if _, err := db.ExecContext(ctx, fmt.Sprintf(notifyTriggerSQL, pq.QuoteLiteral(*channel))); err != nil {
+					return fmt.Errorf("failed to install trigger: %w", err)
+				}
+				if env.JSON() {
+					return env.WriteJSON(map[string]any{"channel": *channel, "trigger_installed": true})
+				}
+				env.Printf("Notify trigger installed on channel %q.\n", *channel)
+				return nil
+			}
+
+			var wake <-chan *pq.Notification
+			if *listen {
+				dsn, _ := env.DatabaseURL()
+				l := pq.NewListener(dsn, time.Second, time.Minute, nil)
+				defer l.Close()
+				if err := l.Listen(*channel); err != nil {
+					return &exitError{ExitDatabase, fmt.Errorf("failed to listen on %q: %w", *channel, err)}
+				}
+				wake = l.Notify
+			}
+
+			p := printer{w: env.Stdout, json: env.JSON(), color: colored}
+			if err := follow(ctx, db, filter, *lines, *interval, *window, wake, p.print); err != nil && ctx.Err() == nil {
+				return err
+			}
+			return nil
+		}
+	},
+}
+
+// buildFilter returns a function applying the command line filters to a new
+// query.
+func buildFilter(service, levels, minLevel, reqID, traceID string, fields []string) (func() *query.Query, error) {
+	var lvl *zapcore.Level
+	if minLevel != "" {
+		l, err := zapcore.ParseLevel(minLevel)
+		if err != nil {
+			return nil, err
+		}
+		lvl = &l
+	}
+
+	type cond struct {
+		path  string
+		op    query.Op
+		value any
+	}
+	var conds []cond
+	for _, f := range fields {
+		path, op, value, err := query.ParseCondition(f)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond{path, op, value})
+	}
+
+	filter := func() *query.Query {
+		q := query.New()
+		if service != "" {
+			q.Service(service)
+		}
+		if levels != "" {
+			q.Level(strings.Split(levels, ",")...)
+		}
+		if lvl != nil {
+			q.MinLevel(*lvl)
+		}
+		if reqID != "" {
+			q.RequestID(reqID)
+		}
+		if traceID != "" {
+			q.TraceID(traceID)
+		}
+		for _, c := range conds {
+			q.Field(c.path, c.op, c.value)
+		}
+		return q
+	}
+
+	if _, _, err := filter().SQL(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// follow prints the last n matching entries, then polls the table for new
+// rows every interval or when wake fires.
+//
+// Batches commit out of id order, so each poll reads from the last id before
+// which no row can still appear (see query.SafeID), waiting up to window for
+// missing ids; rows read again are skipped by id.
+func follow(ctx context.Context, db *sql.DB, filter func() *query.Query, n int, interval, window time.Duration, wake <-chan *pq.Notification, emit func(query.LogEntry)) error {
+	hi, err := query.MaxID(ctx, db)
+	if err != nil {
+		return err
+	}
+	last, err := query.FollowStart(ctx, db, hi, window)
+	if err != nil {
+		return err
+	}
+
+	// scan calls fn for the matching rows of (from, upTo] in id order.
+	scan := func(from, upTo int64, fn func(query.LogEntry)) error {
+		for from < upTo {
+			page, err := filter().IDRange(from, upTo).Ascending().Limit(tailBatchSize).Run(ctx, db)
+			if err != nil {
+				return err
+			}
+			for _, e := range page.Entries {
+				fn(e)
+			}
+			if page.Next == nil {
+				break
+			}
+			from = page.Entries[len(page.Entries)-1].ID
+		}
+		return nil
+	}
+
+	// seen holds the ids above last already emitted, or visible at start and
+	// only shown through the n last lines.
+	seen := map[int64]bool{}
+	if err := scan(last, hi, func(e query.LogEntry) { seen[e.ID] = true }); err != nil {
+		return err
+	}
+	if n > 0 {
+		page, err := filter().IDRange(0, hi).Limit(n).Run(ctx, db)
+		if err != nil {
+			return err
+		}
+		for i := len(page.Entries) - 1; i >= 0; i-- {
+			e := page.Entries[i]
+			if e.ID > last {
+				seen[e.ID] = true
+			}
+			emit(e)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-wake:
+		}
+
+		hi, err := query.MaxID(ctx, db)
+		if err != nil {
+			return err
+		}
+		safe, err := query.SafeID(ctx, db, last, hi, window)
+		if err != nil {
+			return err
+		}
+		err = scan(last, hi, func(e query.LogEntry) {
+			if seen[e.ID] {
+				return
+			}
+			if e.ID > safe {
+				seen[e.ID] = true
+			}
+			emit(e)
+		})
+		if err != nil {
+			return err
+		}
+
+		last = safe
+		for id := range seen {
+			if id <= last {
+				delete(seen, id)
+			}
+		}
+	}
+}
+
+// printer writes entries as JSON lines or in a human readable form.
+type printer struct {
+	w     io.Writer
+	json  bool
+	color bool
+}
+
+// skipKeys are the payload keys already shown in the line prefix.
+var skipKeys = map[string]bool{
+	"ts": true, "level": true, "msg": true, "service": true, "caller": true, "stack": true,
+}
+
+// print writes e followed by a newline. In the human readable form, control
+// characters of the logged values are escaped.
+func (p printer) print(e query.LogEntry) {
+	if p.json {
+		fmt.Fprintf(p.w, "%s\n", e.Raw)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(paint(p.color, ansiGray, e.LoggedAt().Local().Format("2006-01-02 15:04:05.000")))
+	b.WriteByte(' ')
+	b.WriteString(paint(p.color, levelColor(e.Level), fmt.Sprintf("%-5s", strings.ToUpper(escapeControl(e.Level)))))
+	if e.Service != "" {
+		b.WriteString(" " + paint(p.color, ansiBlue, "["+escapeControl(e.Service)+"]"))
+	}
+	b.WriteString(" " + paint(p.color, ansiBold, escapeControl(e.Message)))
+
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		if !skipKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(" " + paint(p.color, ansiDim, escapeControl(k)+"=") + formatValue(e.Fields[k]))
+	}
+
+	if e.Stacktrace != "" {
+		for _, line := range strings.Split(e.Stacktrace, "\n") {
+			b.WriteString("\n    " + paint(p.color, ansiGray, escapeControl(line)))
+		}
+	}
+	fmt.Fprintln(p.w, b.String())
+}
+
+// levelColor returns the color of a zap level name.
+func levelColor(level string) string {
+	switch level {
+	case "debug":
+		return ansiGray
+	case "info":
+		return ansiGreen
+	case "warn":
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
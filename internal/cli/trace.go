@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZiplEix/better-logs/query"
+)
+
+// hiddenKeys are payload keys shown in their own column or shared by the whole
+// trace.
+var hiddenKeys = map[string]bool{
+	"ts": true, "level": true, "msg": true, "service": true, "caller": true, "stack": true,
+	"request_id": true, "trace_id": true,
+}
+
+var traceCmd = &Command{
+	Name:        "trace",
+	Summary:     "show every log line of a request as a timeline",
+	Usage:       "-req-id <id> [-trace-id <id>]",
+	Description: "Prints every log line of a request, across all services, as a timeline.\nWith -output json, the raw JSON lines are printed in order.\nAn unknown request prints nothing and exits with status 0.",
+	Timeout:     30 * time.Second,
+	Flags: func(fs *flag.FlagSet) func(context.Context, *Env) error {
+		reqID := fs.String("req-id", "", "request ID to reconstruct")
+		traceID := fs.String("trace-id", "", "trace ID to reconstruct (combined with -req-id if both are set)")
+		jsonOut := fs.Bool("json", false, "same as -output json")
+		color := fs.String("color", "auto", "colorize output: auto, always or never")
+		summaries := fs.String("summary-msg", "http_request,grpc_request,job_run", "messages highlighted as request summaries (comma separated)")
+
+		return func(ctx context.Context, env *Env) error {
+			if *jsonOut {
+				env.Output = "json"
+			}
+			if *reqID == "" && *traceID == "" {
+				return usageErrorf("-req-id or -trace-id is required")
+			}
+			colored, err := useColor(*color, env.Stdout, env)
+			if err != nil {
+				return err
+			}
+
+			db, err := env.DB(ctx)
+			if err != nil {
+				return err
+			}
+
+			entries, err := query.Trace(ctx, db, *reqID, *traceID)
+			if err != nil {
+				return err
+			}
+			if env.JSON() {
+				for _, e := range entries {
+					fmt.Fprintf(env.Stdout, "%s\n", e.Raw)
+				}
+				return nil
+			}
+
+			if len(entries) == 0 {
+				fmt.Fprintln(env.Stderr, "no logs found")
+				return nil
+			}
+
+			t := timeline{w: env.Stdout, color: colored, summaries: map[string]bool{}}
+			for _, m := range splitList(*summaries) {
+				t.summaries[m] = true
+			}
+			t.render(entries)
+			return nil
+		}
+	},
+}
+
+// timeline renders the entries of a trace with offsets relative to the first.
+type timeline struct {
+	w         io.Writer
+	color     bool
+	summaries map[string]bool
+}
+
+// render writes a header line then one line per entry. Summary lines are
+// bold, warnings yellow and errors red.
+func (t timeline) render(entries []query.LogEntry) {
+	start := entries[0].LoggedAt()
+	end := entries[len(entries)-1].LoggedAt()
+
+	services := map[string]bool{}
+	var errs int
+	for _, e := range entries {
+		services[e.Service] = true
+		if isError(e.Level) {
+			errs++
+		}
+	}
+	fmt.Fprintf(t.w, "%d lines, %d services, %d errors, %s\n\n",
+		len(entries), len(services), errs, end.Sub(start).Round(time.Microsecond))
+
+	rows := make([][]string, len(entries))
+	var widths [5]int
+	for i, e := range entries {
+		offset := fmt.Sprintf("+%.3fms", float64(e.LoggedAt().Sub(start).Microseconds())/1000)
+		rows[i] = []string{offset, e.Service, strings.ToUpper(e.Level), e.Caller, e.Message}
+		for j, cell := range rows[i] {
+			widths[j] = max(widths[j], len(cell))
+		}
+	}
+
+	for i, e := range entries {
+		// Pad cells before coloring so escape codes do not break alignment.
+		var b strings.Builder
+		for j, cell := range rows[i] {
+			fmt.Fprintf(&b, "%-*s  ", widths[j], cell)
+		}
+		b.WriteString(traceFields(e.Fields))
+		line := b.String()
+
+		marker := " "
+		if t.summaries[e.Message] {
+			marker = ">"
+		}
+		switch {
+		case isError(e.Level):
+			marker = "!"
+			line = paint(t.color, ansiRed, line)
+		case e.Level == "warn":
+			line = paint(t.color, ansiYellow, line)
+		case t.summaries[e.Message]:
+			line = paint(t.color, ansiBold, line)
+		default:
+			line = paint(t.color, ansiGray, line[:widths[0]]) + line[widths[0]:]
+		}
+		fmt.Fprintln(t.w, marker+" "+line)
+	}
+}
+
+// traceFields formats the payload keys not shown in other columns as
+// key=value pairs sorted by key.
+func traceFields(fields map[string]any) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if !hiddenKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+formatValue(fields[k]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,38 @@
+// Package ids generates the identifiers shared by the better-logs packages.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// NewUUIDv7 returns a random, time-ordered UUID (RFC 9562 version 7) in its
+// canonical textual form.
+func NewUUIDv7() string {
+	var u [16]byte
+
+	// 48-bit big-endian unix timestamp in milliseconds.
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(u[0:6], ts[2:8])
+
+	// The remaining bits are random; crypto/rand.Read never fails.
+	_, _ = rand.Read(u[6:])
+
+	u[6] = (u[6] & 0x0f) | 0x70 // version 7
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 9562 variant
+
+	var out [36]byte
+	hex.Encode(out[0:8], u[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], u[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], u[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], u[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], u[10:])
+	return string(out[:])
+}
@@ -0,0 +1,146 @@
+package betterlogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/ZiplEix/better-logs/internal/ids"
+	"github.com/ZiplEix/better-logs/logctx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log keys identifying a job run, available to fn as context fields.
+const (
+	JobField      = "job"
+	JobRunIDField = "job_run_id"
+)
+
+// Outcomes of a job run, logged under the "outcome" key.
+const (
+	JobSuccess  = "success"
+	JobError    = "error"
+	JobCanceled = "canceled"
+	JobPanic    = "panic"
+)
+
+// JobConfig controls how Job instruments a run.
+type JobConfig struct {
+	// Logger is the logger used for the summary log, also returned by
+	// logctx.Logger inside fn. If nil, the logger of ctx (or zap.L()) is used.
+	Logger *zap.Logger
+
+	// Message is the message of the summary log. If empty, "job_run" is used.
+	Message string
+
+	// RunIDGenerator creates the run id of each execution.
+	// If nil, a UUIDv7 (as generated by httpmw.NewUUIDv7) is used.
+	RunIDGenerator func() string
+
+	// LogStart writes a "job_start" debug log when the run begins. The summary
+	// log is always written.
+	LogStart bool
+
+	// Repanic re-raises a panic of fn after it has been logged, instead of
+	// returning it as an error.
+	Repanic bool
+}
+
+// DefaultJobConfig returns a sane default configuration.
+func DefaultJobConfig() JobConfig {
+	return JobConfig{
+		Message:        "job_run",
+		RunIDGenerator: ids.NewUUIDv7,
+	}
+}
+
+// Job runs fn as a background job (cron task, queue consumer, ...) with
+// DefaultJobConfig. See JobWithConfig.
+func Job(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	return JobWithConfig(ctx, DefaultJobConfig(), name, fn)
+}
+
+// JobWithConfig runs fn and writes a single summary log for the run, the job
+// counterpart of httpmw's http_request log.
+//
+// fn receives a child context (see logctx.Child) holding the job name and a new
+// run id; fields it adds with logctx.AddField end up on the summary log without
+// leaking into ctx. The summary holds the start time, duration, outcome and
+// error. A panic in fn is recovered, logged with its stack and returned as an
+// error (unless cfg.Repanic is set).
+func JobWithConfig(ctx context.Context, cfg JobConfig, name string, fn func(ctx context.Context) error) (err error) {
+	def := DefaultJobConfig()
+	if cfg.Message == "" {
+		cfg.Message = def.Message
+	}
+	if cfg.RunIDGenerator == nil {
+		cfg.RunIDGenerator = def.RunIDGenerator
+	}
+
+	ctx = logctx.Child(ctx)
+	if cfg.Logger != nil {
+		ctx = logctx.WithLogger(ctx, cfg.Logger)
+	}
+	ctx = logctx.WithFields(ctx, map[string]any{
+		JobField:      name,
+		JobRunIDField: cfg.RunIDGenerator(),
+	})
+
+	logger := logctx.BaseLogger(ctx)
+	start := time.Now()
+	if cfg.LogStart {
+		if ce := logger.Check(zapcore.DebugLevel, "job_start"); ce != nil {
+			ce.Write(logctx.ZapFields(logctx.FieldsFrom(ctx))...)
+		}
+	}
+
+	rec, stack, err := runJob(ctx, fn)
+	dur := time.Since(start)
+
+	outcome, lvl := JobSuccess, zapcore.InfoLevel
+	fields := []zap.Field{
+		zap.Time("started_at", start),
+		zap.Float64("duration_ms", float64(dur.Microseconds())/1000),
+	}
+	switch {
+	case rec != nil:
+		outcome, lvl = JobPanic, zapcore.ErrorLevel
+		err = fmt.Errorf("better-logs: job %q panicked: %v", name, rec)
+		fields = append(fields,
+			zap.String("panic", fmt.Sprint(rec)),
+			zap.ByteString("panic_stack", stack),
+		)
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		outcome, lvl = JobCanceled, zapcore.WarnLevel
+		fields = append(fields, zap.Error(err))
+	case err != nil:
+		outcome, lvl = JobError, zapcore.ErrorLevel
+		fields = append(fields, zap.Error(err))
+	}
+	fields = append(fields, zap.String("outcome", outcome))
+	fields = append(fields, logctx.ZapFields(logctx.FieldsFrom(ctx))...)
+
+	if ce := logger.Check(lvl, cfg.Message); ce != nil {
+		ce.Write(fields...)
+	}
+
+	if rec != nil && cfg.Repanic {
+		panic(rec)
+	}
+	return err
+}
+
+// runJob calls fn and recovers from a panic raised by it, returning the panic
+// value and the stack of the panicking goroutine.
+func runJob(ctx context.Context, fn func(ctx context.Context) error) (rec any, stack []byte, err error) {
+	defer func() {
+		if rec = recover(); rec != nil {
+			stack = debug.Stack()
+		}
+	}()
+
+	return nil, nil, fn(ctx)
+}
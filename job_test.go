@@ -0,0 +1,132 @@
+package betterlogs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ZiplEix/better-logs/logctx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newJobConfig(t *testing.T) (JobConfig, *observer.ObservedLogs) {
+	t.Helper()
+
+	obs, logs := observer.New(zapcore.DebugLevel)
+	cfg := DefaultJobConfig()
+	cfg.Logger = zap.New(obs)
+	cfg.RunIDGenerator = func() string { return "run-1" }
+	return cfg, logs
+}
+
+func TestJob_Success(t *testing.T) {
+	cfg, logs := newJobConfig(t)
+	cfg.LogStart = true
+
+	parent := logctx.AddField(context.Background(), "tenant", "acme")
+	err := JobWithConfig(parent, cfg, "cleanup", func(ctx context.Context) error {
+		if logctx.String(ctx, JobRunIDField) != "run-1" {
+			t.Errorf("expected run id in the job context")
+		}
+		logctx.AddField(ctx, "deleted", 3)
+		logctx.Logger(ctx).Info("inside job")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Job returned error: %v", err)
+	}
+
+	if _, ok := logctx.FieldsFrom(parent)["deleted"]; ok {
+		t.Errorf("job fields must not leak into the parent context")
+	}
+	if n := logs.FilterMessage("job_start").Len(); n != 1 {
+		t.Errorf("expected 1 job_start log, got %d", n)
+	}
+	if n := logs.FilterMessage("inside job").Len(); n != 1 {
+		t.Errorf("expected logctx.Logger to use cfg.Logger, got %d logs", n)
+	}
+
+	runs := logs.FilterMessage("job_run").All()
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 job_run log, got %d", len(runs))
+	}
+	fm := runs[0].ContextMap()
+	if runs[0].Level != zapcore.InfoLevel || fm["outcome"] != JobSuccess {
+		t.Errorf("expected success at info, got %v %v", runs[0].Level, fm["outcome"])
+	}
+	if fm["job"] != "cleanup" || fm["job_run_id"] != "run-1" || fm["tenant"] != "acme" || fm["deleted"] != int64(3) {
+		t.Errorf("unexpected context fields: %v", fm)
+	}
+	if _, ok := fm["duration_ms"]; !ok {
+		t.Errorf("expected duration_ms")
+	}
+	if _, ok := fm["started_at"]; !ok {
+		t.Errorf("expected started_at")
+	}
+}
+
+func TestJob_ErrorAndCanceled(t *testing.T) {
+	cfg, logs := newJobConfig(t)
+	boom := errors.New("boom")
+
+	if err := JobWithConfig(context.Background(), cfg, "a", func(context.Context) error { return boom }); err != boom {
+		t.Errorf("expected fn error returned, got %v", err)
+	}
+	if err := JobWithConfig(context.Background(), cfg, "b", func(context.Context) error { return context.Canceled }); err != context.Canceled {
+		t.Errorf("expected context.Canceled returned, got %v", err)
+	}
+
+	runs := logs.FilterMessage("job_run").All()
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 job_run logs, got %d", len(runs))
+	}
+	if runs[0].Level != zapcore.ErrorLevel || runs[0].ContextMap()["outcome"] != JobError || runs[0].ContextMap()["error"] != "boom" {
+		t.Errorf("unexpected error run: %v %v", runs[0].Level, runs[0].ContextMap())
+	}
+	if runs[1].Level != zapcore.WarnLevel || runs[1].ContextMap()["outcome"] != JobCanceled {
+		t.Errorf("unexpected canceled run: %v %v", runs[1].Level, runs[1].ContextMap())
+	}
+}
+
+func TestJob_RecoversPanic(t *testing.T) {
+	cfg, logs := newJobConfig(t)
+
+	err := JobWithConfig(context.Background(), cfg, "explode", func(context.Context) error {
+		panic("kaboom")
+	})
+	if err == nil {
+		t.Fatalf("expected the panic returned as an error")
+	}
+
+	runs := logs.FilterMessage("job_run").All()
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 job_run log, got %d", len(runs))
+	}
+	fm := runs[0].ContextMap()
+	if runs[0].Level != zapcore.ErrorLevel || fm["outcome"] != JobPanic || fm["panic"] != "kaboom" {
+		t.Errorf("unexpected panic run: %v %v", runs[0].Level, fm)
+	}
+	if s, _ := fm["panic_stack"].(string); s == "" {
+		t.Errorf("expected panic_stack")
+	}
+}
+
+func TestJob_Repanic(t *testing.T) {
+	cfg, logs := newJobConfig(t)
+	cfg.Repanic = true
+
+	defer func() {
+		if rec := recover(); rec != "kaboom" {
+			t.Errorf("expected the panic re-raised, got %v", rec)
+		}
+		if n := logs.FilterMessage("job_run").Len(); n != 1 {
+			t.Errorf("expected the run logged before re-panicking, got %d", n)
+		}
+	}()
+
+	_ = JobWithConfig(context.Background(), cfg, "explode", func(context.Context) error {
+		panic("kaboom")
+	})
+}
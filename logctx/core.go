@@ -0,0 +1,89 @@
+package logctx
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ContextKey is the conventional key of the field carrying a context, as in
+// zap.Any("ctx", ctx).
+const ContextKey = "ctx"
+
+// Context returns a field carrying ctx. Cores wrapped with NewCore replace it
+// with the fields stored in ctx; other cores ignore it.
+func Context(ctx context.Context) zap.Field {
+	return zap.Field{Key: ContextKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// NewCore wraps core so that any field carrying a context.Context (built with
+// Context or zap.Any(key, ctx)) is replaced by the fields stored in that
+// context, e.g.
+//
+//	logger.Info("order created", logctx.Context(ctx))
+func NewCore(core zapcore.Core) zapcore.Core {
+	return &ctxCore{Core: core}
+}
+
+// ctxCore expands context fields before writing to Core.
+type ctxCore struct {
+	zapcore.Core
+}
+
+// With implements zapcore.Core.
+func (c *ctxCore) With(fields []zapcore.Field) zapcore.Core {
+	return &ctxCore{Core: c.Core.With(expand(fields))}
+}
+
+// Check implements zapcore.Core.
+func (c *ctxCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core.
+func (c *ctxCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return writeThrough(c.Core, ent, expand(fields))
+}
+
+// expand replaces context fields by the fields they carry. It returns fields
+// untouched when there is nothing to expand.
+func expand(fields []zapcore.Field) []zapcore.Field {
+	idx := -1
+	for i, f := range fields {
+		if contextOf(f) != nil {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return fields
+	}
+
+	out := make([]zapcore.Field, 0, len(fields)+4)
+	out = append(out, fields[:idx]...)
+	for _, f := range fields[idx:] {
+		if ctx := contextOf(f); ctx != nil {
+			out = append(out, ZapFields(FieldsFrom(ctx))...)
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// contextOf returns the context carried by f, if any. zap.Any encodes a
+// context either as a reflected value or, for contexts implementing
+// fmt.Stringer, as a stringer.
+func contextOf(f zapcore.Field) context.Context {
+	switch f.Type {
+	case zapcore.SkipType, zapcore.ReflectType, zapcore.StringerType:
+		if ctx, ok := f.Interface.(context.Context); ok {
+			return ctx
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package logctx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewCore_ExpandsContextFields(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(NewCore(obs))
+
+	ctx := WithFields(context.Background(), map[string]any{"request_id": "r-1"})
+
+	logger.Info("with helper", Context(ctx), zap.String("k", "v"))
+	logger.Info("with zap.Any", zap.Any("ctx", ctx))
+	logger.With(Context(ctx)).Info("with With")
+	logger.Info("plain", zap.String("k", "v"))
+	logger.Debug("disabled", Context(ctx))
+
+	entries := logs.All()
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	for _, e := range entries[:3] {
+		fm := e.ContextMap()
+		if fm["request_id"] != "r-1" {
+			t.Errorf("%s: expected request_id from ctx, got %v", e.Message, fm)
+		}
+		if _, ok := fm["ctx"]; ok {
+			t.Errorf("%s: the ctx field must not be logged", e.Message)
+		}
+	}
+	if fm := entries[0].ContextMap(); fm["k"] != "v" {
+		t.Errorf("expected other fields kept, got %v", fm)
+	}
+	if fm := entries[3].ContextMap(); len(fm) != 1 || fm["k"] != "v" {
+		t.Errorf("expected plain entry untouched, got %v", fm)
+	}
+}
+
+func TestNewCore_KeepsTeeLevels(t *testing.T) {
+	infoObs, infoLogs := observer.New(zapcore.InfoLevel)
+	errObs, errLogs := observer.New(zapcore.ErrorLevel)
+	logger := zap.New(NewCore(zapcore.NewTee(infoObs, errObs)))
+
+	ctx := WithFields(context.Background(), map[string]any{"a": 1})
+	logger.Info("info", Context(ctx))
+	logger.Error("error", Context(ctx))
+
+	if infoLogs.Len() != 2 || errLogs.Len() != 1 {
+		t.Errorf("expected 2 info-level and 1 error-level entries, got %d and %d", infoLogs.Len(), errLogs.Len())
+	}
+}
+
+// failingSyncer is a WriteSyncer whose writes fail.
+type failingSyncer struct{}
+
+func (failingSyncer) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+func (failingSyncer) Sync() error               { return nil }
+
+func TestCores_ReturnWriteErrors(t *testing.T) {
+	failing := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), failingSyncer{}, zapcore.InfoLevel)
+	obs, logs := observer.New(zapcore.InfoLevel)
+	tee := zapcore.NewTee(obs, failing)
+	h := getHolder(WithFields(context.Background(), map[string]any{"a": 1}))
+
+	cores := map[string]zapcore.Core{
+		"ctxCore":  NewCore(tee),
+		"lazyCore": &lazyCore{Core: tee, h: h},
+	}
+	for name, core := range cores {
+		err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: name}, nil)
+		if err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("%s: expected the write error, got %v", name, err)
+		}
+	}
+	if logs.Len() != 2 {
+		t.Errorf("expected the other tee core to still be written, got %d entries", logs.Len())
+	}
+
+	if err := NewCore(obs).Write(zapcore.Entry{Level: zapcore.InfoLevel}, nil); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+// Package logctx stores structured log fields in a context.Context so that any
+// code path (HTTP or gRPC handlers, background workers, queue consumers, CLI
+// jobs) can enrich the logs written for the current unit of work.
+package logctx
+
+import (
+	"context"
+	"sync"
+)
+
+// ctxKey is the private key type used to store extra log fields in context.
+type ctxKey struct{}
+
+// fieldsHolder holds mutable log fields shared across middleware + handlers.
+//
+// A child holder (see Child) reads through to its parent but only ever writes
+// to its own map, recording deletions of inherited keys as tombstones.
+type fieldsHolder struct {
+	mu      sync.Mutex
+	parent  *fieldsHolder
+	m       map[string]any
+	deleted map[string]struct{}
+}
+
+// snapshot returns a copy of all the fields visible from h.
+func (h *fieldsHolder) snapshot() map[string]any {
+	var out map[string]any
+	if h.parent != nil {
+		out = h.parent.snapshot()
+	} else {
+		out = make(map[string]any)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for k := range h.deleted {
+		delete(out, k)
+	}
+	for k, v := range h.m {
+		out[k] = v
+	}
+	return out
+}
+
+// getHolder returns the fieldsHolder stored in the context, if any.
+func getHolder(ctx context.Context) *fieldsHolder {
+	if v := ctx.Value(ctxKey{}); v != nil {
+		if h, ok := v.(*fieldsHolder); ok {
+			return h
+		}
+	}
+	return nil
+}
+
+// ensureHolder returns an existing fieldsHolder from ctx or creates a new one
+// and returns the (possibly) updated context.
+func ensureHolder(ctx context.Context) (*fieldsHolder, context.Context) {
+	if h := getHolder(ctx); h != nil {
+		return h, ctx
+	}
+	h := &fieldsHolder{m: make(map[string]any)}
+	ctx = context.WithValue(ctx, ctxKey{}, h)
+	return h, ctx
+}
+
+// FieldsFrom returns a COPY of all custom log fields stored in the context, if any.
+func FieldsFrom(ctx context.Context) map[string]any {
+	h := getHolder(ctx)
+	if h == nil {
+		return map[string]any{}
+	}
+	return h.snapshot()
+}
+
+// WithFields merges the provided key/value pairs into the context's logging fields.
+// It returns the (possibly) updated context. The underlying holder is shared, so
+// even if the returned context is ignored, the fields are still added.
+func WithFields(ctx context.Context, kv map[string]any) context.Context {
+	h, ctx2 := ensureHolder(ctx)
+	if len(kv) == 0 {
+		return ctx2
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k, v := range kv {
+		h.m[k] = v
+		delete(h.deleted, k)
+	}
+	return ctx2
+}
+
+// AddField adds a single field into the context's logging fields.
+func AddField(ctx context.Context, key string, value any) context.Context {
+	return WithFields(ctx, map[string]any{key: value})
+}
+
+// Delete removes the given keys from the context's logging fields. In a child
+// holder, inherited keys are hidden from the child without touching the parent.
+func Delete(ctx context.Context, keys ...string) {
+	h := getHolder(ctx)
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range keys {
+		delete(h.m, k)
+		if h.parent != nil {
+			if h.deleted == nil {
+				h.deleted = make(map[string]struct{})
+			}
+			h.deleted[k] = struct{}{}
+		}
+	}
+}
+
+// Child returns a context with a new fields holder scoped to it. The child sees
+// the fields of its parent (including ones added later), but fields added to or
+// deleted from the child never affect the parent. Use it for goroutines spawned
+// from a request.
+func Child(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &fieldsHolder{
+		parent: getHolder(ctx),
+		m:      make(map[string]any),
+	})
+}
+
+// Get returns the field stored under key, if it exists and has type T.
+func Get[T any](ctx context.Context, key string) (T, bool) {
+	v, ok := FieldsFrom(ctx)[key].(T)
+	return v, ok
+}
+
+// String returns the string field stored under key, or "".
+func String(ctx context.Context, key string) string {
+	v, _ := Get[string](ctx, key)
+	return v
+}
@@ -0,0 +1,104 @@
+package logctx
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestWithFieldsAndAddField(t *testing.T) {
+	ctx := WithFields(context.Background(), map[string]any{"a": 1})
+	AddField(ctx, "b", "two") // the holder is shared, the returned ctx can be ignored
+
+	fm := FieldsFrom(ctx)
+	if fm["a"] != 1 || fm["b"] != "two" {
+		t.Fatalf("unexpected fields: %v", fm)
+	}
+
+	fm["c"] = true
+	if _, ok := FieldsFrom(ctx)["c"]; ok {
+		t.Errorf("FieldsFrom must return a copy")
+	}
+
+	if got := FieldsFrom(context.Background()); len(got) != 0 {
+		t.Errorf("expected no fields on a bare context, got %v", got)
+	}
+}
+
+func TestChild_DoesNotMutateParent(t *testing.T) {
+	parent := WithFields(context.Background(), map[string]any{"request_id": "r-1", "user_id": "u-1"})
+	child := Child(parent)
+
+	AddField(child, "job", "resize")
+	Delete(child, "user_id")
+
+	if fm := FieldsFrom(parent); fm["user_id"] != "u-1" || fm["job"] != nil {
+		t.Errorf("parent must not see child changes, got %v", fm)
+	}
+
+	fm := FieldsFrom(child)
+	if fm["request_id"] != "r-1" || fm["job"] != "resize" {
+		t.Errorf("expected inherited and own fields, got %v", fm)
+	}
+	if _, ok := fm["user_id"]; ok {
+		t.Errorf("expected user_id deleted in child, got %v", fm)
+	}
+
+	// Fields added to the parent later are visible from the child, and a
+	// deleted key can be set again.
+	AddField(parent, "tenant", "acme")
+	AddField(child, "user_id", "u-2")
+	if fm := FieldsFrom(child); fm["tenant"] != "acme" || fm["user_id"] != "u-2" {
+		t.Errorf("expected late parent field and re-added key, got %v", fm)
+	}
+}
+
+func TestChild_ConcurrentWrites(t *testing.T) {
+	parent := WithFields(context.Background(), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			child := Child(parent)
+			AddField(child, "worker", i)
+			AddField(parent, "last", i)
+			_ = FieldsFrom(child)
+		}(i)
+	}
+	wg.Wait()
+
+	if _, ok := FieldsFrom(parent)["worker"]; ok {
+		t.Errorf("child fields leaked into the parent")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := WithFields(context.Background(), map[string]any{"a": 1, "b": 2})
+	Delete(ctx, "a", "missing")
+
+	if fm := FieldsFrom(ctx); len(fm) != 1 || fm["b"] != 2 {
+		t.Errorf("unexpected fields after Delete: %v", fm)
+	}
+
+	// No holder: no-op.
+	Delete(context.Background(), "a")
+}
+
+func TestGetAndString(t *testing.T) {
+	ctx := WithFields(context.Background(), map[string]any{"n": 42, "s": "x"})
+
+	if n, ok := Get[int](ctx, "n"); !ok || n != 42 {
+		t.Errorf("Get[int] = %v, %v", n, ok)
+	}
+	if _, ok := Get[string](ctx, "n"); ok {
+		t.Errorf("expected Get with the wrong type to fail")
+	}
+	if _, ok := Get[int](ctx, "missing"); ok {
+		t.Errorf("expected Get of a missing key to fail")
+	}
+	if String(ctx, "s") != "x" || String(ctx, "n") != "" {
+		t.Errorf("unexpected String results")
+	}
+}
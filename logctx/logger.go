@@ -0,0 +1,137 @@
+package logctx
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// loggerKey is the private key type used to store the base logger.
+type loggerKey struct{}
+
+// WithLogger returns a context carrying l as the base logger used by Logger,
+// LazyLogger and BaseLogger (e.g. the logger configured on a middleware).
+func WithLogger(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// BaseLogger returns the logger stored with WithLogger, or the global logger,
+// without any context field.
+func BaseLogger(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return zap.L()
+}
+
+// Logger returns the base logger (see BaseLogger) pre-bound with the fields
+// currently stored in ctx.
+//
+// Fields added to ctx after this call are not included; use LazyLogger if the
+// logger outlives further AddField/WithFields calls.
+func Logger(ctx context.Context) *zap.Logger {
+	fields := ZapFields(FieldsFrom(ctx))
+	if len(fields) == 0 {
+		return BaseLogger(ctx)
+	}
+	return BaseLogger(ctx).With(fields...)
+}
+
+// LazyLogger returns the base logger bound to the fields holder of ctx:
+// context fields are resolved on every log call, so fields added later via
+// AddField/WithFields still appear on subsequent lines.
+func LazyLogger(ctx context.Context) *zap.Logger {
+	h := getHolder(ctx)
+	if h == nil {
+		return BaseLogger(ctx)
+	}
+	return BaseLogger(ctx).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &lazyCore{Core: c, h: h}
+	}))
+}
+
+// lazyCore appends the holder's fields to every entry written to Core.
+type lazyCore struct {
+	zapcore.Core
+	h *fieldsHolder
+}
+
+// With implements zapcore.Core.
+func (c *lazyCore) With(fields []zapcore.Field) zapcore.Core {
+	return &lazyCore{Core: c.Core.With(fields), h: c.h}
+}
+
+// Check implements zapcore.Core.
+func (c *lazyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core.
+func (c *lazyCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(fields)+4)
+	all = append(all, fields...)
+	all = append(all, ZapFields(c.h.snapshot())...)
+	return writeThrough(c.Core, ent, all)
+}
+
+// writeThrough writes an entry to core, checking it again so that each
+// underlying core (e.g. in a tee) keeps applying its own level.
+//
+// CheckedEntry.Write does not return the errors of the cores but prints them
+// to its ErrorOutput, so writeThrough captures that output to return them.
+func writeThrough(core zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) error {
+	inner := core.Check(ent, nil)
+	if inner == nil {
+		return nil
+	}
+	var out writeErrorOutput
+	inner.ErrorOutput = &out
+	inner.Write(fields...)
+	return out.err
+}
+
+// writeErrorOutput records the write error reported by CheckedEntry.Write,
+// formatted as "<time> write error: <err>".
+type writeErrorOutput struct {
+	err error
+}
+
+func (o *writeErrorOutput) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	if _, after, ok := strings.Cut(msg, " write error: "); ok {
+		msg = after
+	}
+	o.err = errors.Join(o.err, errors.New(msg))
+	return len(p), nil
+}
+
+func (o *writeErrorOutput) Sync() error {
+	return nil
+}
+
+// ZapFields converts context fields into zap fields, sorted by key so the
+// output is stable.
+func ZapFields(m map[string]any) []zap.Field {
+	if len(m) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]zap.Field, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, zap.Any(k, m[k]))
+	}
+	return fields
+}
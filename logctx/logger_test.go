@@ -0,0 +1,65 @@
+package logctx
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithLogger(t *testing.T) {
+	zap.ReplaceGlobals(zap.NewNop())
+
+	obs, logs := observer.New(zapcore.InfoLevel)
+	ctx := WithLogger(context.Background(), zap.New(obs))
+	ctx = AddField(ctx, "job", "cleanup")
+
+	Logger(ctx).Info("eager")
+	LazyLogger(ctx).Info("lazy")
+	BaseLogger(ctx).Info("base")
+
+	if logs.Len() != 3 {
+		t.Fatalf("expected 3 logs on the context logger, got %d", logs.Len())
+	}
+	for _, e := range logs.All()[:2] {
+		if e.ContextMap()["job"] != "cleanup" {
+			t.Errorf("%s: expected job field, got %v", e.Message, e.ContextMap())
+		}
+	}
+	if fm := logs.All()[2].ContextMap(); len(fm) != 0 {
+		t.Errorf("expected BaseLogger without context fields, got %v", fm)
+	}
+
+	if BaseLogger(context.Background()) != zap.L() {
+		t.Errorf("expected the global logger without WithLogger")
+	}
+}
+
+func TestLazyLogger_Child(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	parent := WithLogger(context.Background(), zap.New(obs))
+	parent = AddField(parent, "request_id", "r-1")
+
+	child := Child(parent)
+	lazy := LazyLogger(child)
+	AddField(child, "worker", 1)
+
+	lazy.Info("from goroutine")
+
+	fm := logs.All()[0].ContextMap()
+	if fm["request_id"] != "r-1" || fm["worker"] != int64(1) {
+		t.Errorf("expected parent and child fields, got %v", fm)
+	}
+}
+
+func TestZapFields_Sorted(t *testing.T) {
+	fields := ZapFields(map[string]any{"b": 1, "a": 2, "c": 3})
+	if len(fields) != 3 || fields[0].Key != "a" || fields[1].Key != "b" || fields[2].Key != "c" {
+		t.Errorf("expected fields sorted by key, got %v", fields)
+	}
+	if ZapFields(nil) != nil {
+		t.Errorf("expected nil for no fields")
+	}
+}
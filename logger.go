@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/ZiplEix/better-logs/logctx"
 	"github.com/ZiplEix/better-logs/pgcore"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -45,11 +47,17 @@ func New(cfg Config) (*zap.Logger, func(context.Context) error, error) {
 			return nil, nil, errors.New("better-logs: EnablePostgres is true but DB is nil")
 		}
 
+		// Fail now rather than losing every batch when the first COPY fails.
+		if err := prepareLogsTable(cfg); err != nil {
+			return nil, nil, err
+		}
+
 		pgCfg := pgcore.Config{
 			Level:      cfg.Level,
 			BatchSize:  cfg.PG.BatchSize,
 			MaxWait:    cfg.PG.MaxWait,
 			BufferSize: cfg.PG.BufferSize,
+			Columns:    cfg.PG.Columns,
 		}
 
 		pgCore, closeFn, err := pgcore.New(cfg.DB, pgCfg)
@@ -64,7 +72,8 @@ func New(cfg Config) (*zap.Logger, func(context.Context) error, error) {
 		return nil, nil, errors.New("better-logs: no logging core enabled (Stdout=false and EnablePostgres=false)")
 	}
 
-	tee := zapcore.NewTee(cores...)
+	// Expand context fields passed as logctx.Context(ctx) or zap.Any("ctx", ctx).
+	tee := logctx.NewCore(zapcore.NewTee(cores...))
 
 	logger := zap.New(
 		tee,
@@ -108,6 +117,24 @@ func New(cfg Config) (*zap.Logger, func(context.Context) error, error) {
 	return logger, cleanup, nil
 }
 
+// prepareLogsTable runs the migration if cfg.PG.AutoMigrate is set, then
+// checks the logs table.
+func prepareLogsTable(cfg Config) error {
+	timeout := cfg.PG.StartupTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if cfg.PG.AutoMigrate {
+		if err := EnsureLogsTable(ctx, cfg.DB, cfg.PG.Columns...); err != nil {
+			return err
+		}
+	}
+	return ValidateLogsTable(ctx, cfg.DB, cfg.PG.Columns...)
+}
+
 // isSyncNoop filters out common non-critical sync errors (e.g. EOF on stdout/stderr).
 func isSyncNoop(err error) bool {
 	// Often zap.Sync() on stdout/stderr returns "invalid argument" on some platforms.
@@ -116,3 +116,82 @@ func TestNew_WithPostgresCore_InsertsLog(t *testing.T) {
 		t.Fatalf("expected at least one log row with msg=%q, got 0", msg)
 	}
 }
+
+func TestNew_AutoMigrateCreatesTable(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS logs`); err != nil {
+		t.Fatalf("failed to drop logs table: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Stdout = false
+	cfg.EnablePostgres = true
+	cfg.DB = db
+
+	if _, _, err := New(cfg); !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("expected ErrInvalidSchema without the table, got %v", err)
+	}
+
+	cfg.PG.AutoMigrate = true
+	logger, cleanup, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New with AutoMigrate returned error: %v", err)
+	}
+	logger.Info("auto migrated")
+	if err := cleanup(ctx); err != nil {
+		t.Logf("cleanup returned error (ignored): %v", err)
+	}
+
+	if err := ValidateLogsTable(ctx, db); err != nil {
+		t.Errorf("expected AutoMigrate to create the table, got %v", err)
+	}
+}
+
+func TestNew_AcceptsLegacyTableWithoutTraceID(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The schema of tables created before trace_id was added.
+	const legacy = `
+DROP TABLE IF EXISTS logs;
+CREATE TABLE logs (
+    id      BIGSERIAL PRIMARY KEY,
+    ts      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    req_id  TEXT,
+    raw     JSONB NOT NULL
+);`
+	if _, err := db.ExecContext(ctx, legacy); err != nil {
+		t.Fatalf("failed to create legacy table: %v", err)
+	}
+	defer EnsureLogsTable(context.Background(), db)
+
+	cfg := DefaultConfig()
+	cfg.Stdout = false
+	cfg.EnablePostgres = true
+	cfg.DB = db
+
+	logger, cleanup, err := New(cfg)
+	if err != nil {
+		t.Fatalf("expected New to accept a table without trace_id, got %v", err)
+	}
+	logger.Info("legacy table", zap.String("request_id", "legacy-req"))
+	if err := cleanup(ctx); err != nil {
+		t.Fatalf("cleanup returned error: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM logs WHERE req_id = 'legacy-req'`).Scan(&count); err != nil {
+		t.Fatalf("failed to count logs: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected the log to be stored, got %d rows", count)
+	}
+}
@@ -0,0 +1,210 @@
+package pgcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column is an extra column of the logs table populated from the JSON
+// payload, so that frequent filters (user_id, tenant_id, status, ...) can use
+// a plain indexed column instead of the raw JSONB.
+//
+// The column must exist before logs are written: pass the same columns to
+// betterlogs.EnsureLogsTable (or the migrate command) to create it.
+type Column struct {
+	// Name is the column name, e.g. "user_id". It must be a lowercase SQL
+	// identifier and must not be one of the built-in columns.
+	Name string `json:"name"`
+
+	// Type is the SQL type of the column: text, bigint, integer,
+	// double precision, boolean or timestamptz (and their usual aliases).
+	// If empty, text is used.
+	Type string `json:"type,omitempty"`
+
+	// Keys lists the top level payload keys that may hold the value; the
+	// first one present wins. If empty, Name is used.
+	Keys []string `json:"keys,omitempty"`
+
+	// Index creates a B-tree index on the column when migrating.
+	Index bool `json:"index,omitempty"`
+}
+
+// columnName matches the accepted column names.
+var columnName = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// builtinColumns are the columns of the logs table managed by the library.
+var builtinColumns = map[string]bool{"id": true, "ts": true, "req_id": true, "trace_id": true, "raw": true}
+
+// sqlTypes maps the accepted type names to their canonical name, as printed
+// by Postgres' format_type.
+var sqlTypes = map[string]string{
+	"text":                     "text",
+	"varchar":                  "text",
+	"bigint":                   "bigint",
+	"int8":                     "bigint",
+	"integer":                  "integer",
+	"int":                      "integer",
+	"int4":                     "integer",
+	"double precision":         "double precision",
+	"float8":                   "double precision",
+	"boolean":                  "boolean",
+	"bool":                     "boolean",
+	"timestamptz":              "timestamp with time zone",
+	"timestamp with time zone": "timestamp with time zone",
+}
+
+// Validate reports whether the name and type of c are supported.
+func (c Column) Validate() error {
+	if !columnName.MatchString(c.Name) {
+		return fmt.Errorf("pgcore: invalid column name %q (lowercase letters, digits and underscores)", c.Name)
+	}
+	if builtinColumns[c.Name] {
+		return fmt.Errorf("pgcore: column %q is built-in", c.Name)
+	}
+	if _, ok := sqlTypes[normalizeType(c.Type)]; !ok {
+		return fmt.Errorf("pgcore: column %q: unsupported type %q", c.Name, c.Type)
+	}
+	return nil
+}
+
+// ValidateColumns validates each column of cols and rejects duplicate names,
+// which would make every COPY fail.
+func ValidateColumns(cols []Column) error {
+	seen := make(map[string]bool, len(cols))
+	for _, c := range cols {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("pgcore: duplicate column %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+// SQLType returns the canonical SQL type of c, e.g. "bigint" or
+// "timestamp with time zone", or "" if the type is not supported.
+func (c Column) SQLType() string {
+	return sqlTypes[normalizeType(c.Type)]
+}
+
+// keys returns the payload keys of c.
+func (c Column) keys() []string {
+	if len(c.Keys) == 0 {
+		return []string{c.Name}
+	}
+	return c.Keys
+}
+
+// normalizeType lowercases t and collapses its spaces, defaulting to text.
+func normalizeType(t string) string {
+	t = strings.Join(strings.Fields(strings.ToLower(t)), " ")
+	if t == "" {
+		return "text"
+	}
+	return t
+}
+
+// Value extracts the value of c from a payload decoded with UseNumber,
+// converted to the column type. It returns nil (NULL) when no key is present
+// or the value does not convert, so that a bad line never fails a batch.
+func (c Column) Value(m map[string]any) any {
+	for _, k := range c.keys() {
+		v, ok := m[k]
+		if !ok || v == nil {
+			continue
+		}
+		return convert(c.SQLType(), v)
+	}
+	return nil
+}
+
+// convert converts a decoded JSON value to a value of the given SQL type.
+func convert(typ string, v any) any {
+	switch typ {
+	case "text":
+		switch v := v.(type) {
+		case string:
+			return v
+		case json.Number:
+			return v.String()
+		case bool:
+			return strconv.FormatBool(v)
+		default:
+			b, _ := json.Marshal(v)
+			return string(b)
+		}
+
+	case "bigint", "integer":
+		var s string
+		switch v := v.(type) {
+		case json.Number:
+			s = v.String()
+		case string:
+			s = strings.TrimSpace(v)
+		default:
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			// Accept integral floats such as 2e3.
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+				return nil
+			}
+			n = int64(f)
+		}
+		if typ == "integer" && (n < math.MinInt32 || n > math.MaxInt32) {
+			return nil
+		}
+		return n
+
+	case "double precision":
+		var s string
+		switch v := v.(type) {
+		case json.Number:
+			s = v.String()
+		case string:
+			s = strings.TrimSpace(v)
+		default:
+			return nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return f
+
+	case "boolean":
+		switch v := v.(type) {
+		case bool:
+			return v
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil
+			}
+			return b
+		}
+		return nil
+
+	case "timestamp with time zone":
+		s, ok := v.(string)
+		if !ok {
+			return nil
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+		return nil
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package pgcore
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -33,6 +34,16 @@ type Config struct {
 	// a request/correlation ID. The first non-empty string found wins.
 	// If empty, sensible defaults are used.
 	RequestIDKeys []string
+
+	// TraceIDKeys lists possible keys in the JSON payload that may contain
+	// a W3C trace ID, stored in the trace_id column.
+	// If empty, sensible defaults are used.
+	TraceIDKeys []string
+
+	// Columns lists extra columns of the logs table filled from the JSON
+	// payload on COPY, e.g. user_id or status. They must exist in the table:
+	// create them with betterlogs.EnsureLogsTable.
+	Columns []Column
 }
 
 // core implements zapcore.Core and ships logs into Postgres in background.
@@ -46,12 +57,20 @@ type core struct {
 	batchSize int
 	maxWait   time.Duration
 	reqKeys   []string
+	traceKeys []string
+	columns   []Column
+
+	// noTraceID is set when the table predates the trace_id column.
+	noTraceID bool
 }
 
 // New creates a zapcore.Core that writes logs into the given Postgres DB,
 // using COPY for batched inserts into a "logs" table with columns
-//   - req_id TEXT
-//   - raw   TEXT
+//   - req_id   TEXT
+//   - trace_id TEXT
+//   - raw      TEXT
+//
+// plus the promoted columns of cfg.Columns.
 //
 // It also returns a close func(ctx) error that waits for pending logs to be
 // flushed. The caller should invoke this during shutdown.
@@ -65,6 +84,9 @@ func New(db *sql.DB, cfg Config) (zapcore.Core, func(context.Context) error, err
 	if cfg.BufferSize <= 0 {
 		cfg.BufferSize = 10_000
 	}
+	if err := ValidateColumns(cfg.Columns); err != nil {
+		return nil, nil, err
+	}
 
 	if len(cfg.RequestIDKeys) == 0 {
 		cfg.RequestIDKeys = []string{
@@ -74,6 +96,17 @@ func New(db *sql.DB, cfg Config) (zapcore.Core, func(context.Context) error, err
 			"X-Request-ID",
 			"X-Correlation-ID",
 			"requestid",
+			// Run id of betterlogs.Job, so that job runs can be traced
+			// like requests when they do not carry a request ID.
+			"job_run_id",
+		}
+	}
+
+	if len(cfg.TraceIDKeys) == 0 {
+		cfg.TraceIDKeys = []string{
+			"trace_id",
+			"traceId",
+			"trace.id",
 		}
 	}
 
@@ -101,6 +134,12 @@ func New(db *sql.DB, cfg Config) (zapcore.Core, func(context.Context) error, err
 		batchSize: cfg.BatchSize,
 		maxWait:   cfg.MaxWait,
 		reqKeys:   cfg.RequestIDKeys,
+		traceKeys: cfg.TraceIDKeys,
+		columns:   append([]Column(nil), cfg.Columns...),
+		noTraceID: lacksTraceID(db),
+	}
+	if c.noTraceID {
+		log.Printf("pgcore: logs table has no trace_id column, trace IDs are not stored (run betterlogs.EnsureLogsTable to upgrade it)")
 	}
 
 	c.wg.Add(1)
@@ -145,6 +184,9 @@ func (c *core) With(fields []zapcore.Field) zapcore.Core {
 		batchSize: c.batchSize,
 		maxWait:   c.maxWait,
 		reqKeys:   c.reqKeys,
+		traceKeys: c.traceKeys,
+		columns:   c.columns,
+		noTraceID: c.noTraceID,
 	}
 
 	for _, f := range fields {
@@ -197,6 +239,16 @@ func (c *core) loop() {
 
 	batch := make([][]byte, 0, c.batchSize)
 
+	copyColumns := []string{"req_id"}
+	if !c.noTraceID {
+		copyColumns = append(copyColumns, "trace_id")
+	}
+	for _, col := range c.columns {
+		copyColumns = append(copyColumns, col.Name)
+	}
+	copyColumns = append(copyColumns, "raw")
+	args := make([]any, 0, len(copyColumns))
+
 	flush := func() {
 		if len(batch) == 0 {
 			return
@@ -209,7 +261,7 @@ func (c *core) loop() {
 			return
 		}
 
-		stmt, err := tx.Prepare(pq.CopyIn("logs", "req_id", "raw"))
+		stmt, err := tx.Prepare(pq.CopyIn("logs", copyColumns...))
 		if err != nil {
 			log.Printf("pgcore: prepare err: %v\n", err)
 			_ = tx.Rollback()
@@ -219,22 +271,23 @@ func (c *core) loop() {
 
 		for _, line := range batch {
 			var tmp map[string]any
-			if json.Unmarshal(line, &tmp) != nil {
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			if dec.Decode(&tmp) != nil {
 				// Ignore non-JSON lines.
 				continue
 			}
 
-			var reqID string
-			for _, k := range c.reqKeys {
-				if v, ok := tmp[k]; ok {
-					if s, ok := v.(string); ok && s != "" {
-						reqID = s
-						break
-					}
-				}
+			args = append(args[:0], firstString(tmp, c.reqKeys))
+			if !c.noTraceID {
+				args = append(args, firstString(tmp, c.traceKeys))
 			}
+			for _, col := range c.columns {
+				args = append(args, col.Value(tmp))
+			}
+			args = append(args, string(line))
 
-			if _, err := stmt.Exec(reqID, string(line)); err != nil {
+			if _, err := stmt.Exec(args...); err != nil {
 				log.Printf("pgcore: exec err: %v\n", err)
 			}
 		}
@@ -281,3 +334,40 @@ func (c *core) loop() {
 		}
 	}
 }
+
+// firstString returns the first non-empty string value found in m for keys.
+func firstString(m map[string]any, keys []string) string {
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// lacksTraceIDSQL reports whether the logs table exists without the trace_id
+// column, as created by versions before trace context support.
+const lacksTraceIDSQL = `
+SELECT to_regclass('logs') IS NOT NULL AND NOT EXISTS (
+    SELECT 1 FROM pg_attribute
+    WHERE attrelid = to_regclass('logs') AND attname = 'trace_id' AND NOT attisdropped
+)`
+
+// lacksTraceID reports whether the logs table of db lacks the trace_id column,
+// in which case COPY falls back to the older column list instead of failing
+// every batch. If the check itself fails, the current schema is assumed.
+func lacksTraceID(db *sql.DB) bool {
+	if db == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var lacks bool
+	if err := db.QueryRowContext(ctx, lacksTraceIDSQL).Scan(&lacks); err != nil {
+		return false
+	}
+	return lacks
+}
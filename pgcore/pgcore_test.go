@@ -3,7 +3,9 @@ package pgcore
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,11 +43,15 @@ CREATE TABLE IF NOT EXISTS logs (
     id      BIGSERIAL PRIMARY KEY,
     ts      TIMESTAMPTZ NOT NULL DEFAULT now(),
     req_id  TEXT,
+    trace_id TEXT,
     raw     JSONB NOT NULL
 );
 
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS trace_id TEXT;
+
 CREATE INDEX IF NOT EXISTS idx_logs_ts ON logs (ts DESC);
 CREATE INDEX IF NOT EXISTS idx_logs_req_id ON logs (req_id);
+CREATE INDEX IF NOT EXISTS idx_logs_trace_id ON logs (trace_id);
 `
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -164,3 +170,249 @@ func TestPgcore_RespectsLogLevel(t *testing.T) {
 		t.Fatalf("expected at least 1 row for msg=%q at level Warn, got 0", msgWarn)
 	}
 }
+
+func TestPgcore_ExtractsTraceID(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ensureLogsTable(t, db)
+
+	core, closeFn, err := New(db, Config{Level: zapcore.InfoLevel})
+	if err != nil {
+		t.Fatalf("pgcore.New returned error: %v", err)
+	}
+
+	logger := zap.New(core)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const msg = "pgcore_test_trace_id"
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	logger.Info(msg, zap.String("trace_id", traceID))
+
+	if err := closeFn(ctx); err != nil {
+		t.Logf("closeFn returned error (ignored): %v", err)
+	}
+
+	var got string
+	if err := db.QueryRowContext(ctx, `SELECT trace_id FROM logs WHERE raw->>'msg' = $1`, msg).Scan(&got); err != nil {
+		t.Fatalf("failed to query logs table: %v", err)
+	}
+	if got != traceID {
+		t.Fatalf("expected trace_id=%q, got %q", traceID, got)
+	}
+}
+
+func TestPgcore_JobRunIDFillsReqID(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ensureLogsTable(t, db)
+
+	core, closeFn, err := New(db, Config{Level: zapcore.InfoLevel})
+	if err != nil {
+		t.Fatalf("pgcore.New returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const msg = "pgcore_test_job_run_id"
+	const runID = "0190b8a2-7c1e-7a3b-9c4d-5e6f7a8b9c0d"
+	zap.New(core).Info(msg, zap.String("job_run_id", runID))
+
+	if err := closeFn(ctx); err != nil {
+		t.Logf("closeFn returned error (ignored): %v", err)
+	}
+
+	var got string
+	if err := db.QueryRowContext(ctx, `SELECT req_id FROM logs WHERE raw->>'msg' = $1`, msg).Scan(&got); err != nil {
+		t.Fatalf("failed to query logs table: %v", err)
+	}
+	if got != runID {
+		t.Fatalf("expected req_id=%q, got %q", runID, got)
+	}
+}
+
+func TestPgcore_LegacyTableWithoutTraceID(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ensureLogsTable(t, db)
+	// Restore the column for the other tests.
+	defer ensureLogsTable(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Simulate a table created before the trace_id column.
+	if _, err := db.ExecContext(ctx, `ALTER TABLE logs DROP COLUMN trace_id`); err != nil {
+		t.Fatalf("failed to drop column: %v", err)
+	}
+
+	core, closeFn, err := New(db, Config{Level: zapcore.InfoLevel})
+	if err != nil {
+		t.Fatalf("pgcore.New returned error: %v", err)
+	}
+
+	const msg = "pgcore_test_legacy_table"
+	zap.New(core).Info(msg, zap.String("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"))
+
+	if err := closeFn(ctx); err != nil {
+		t.Logf("closeFn returned error (ignored): %v", err)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM logs WHERE raw->>'msg' = $1`, msg).Scan(&count); err != nil {
+		t.Fatalf("failed to query logs table: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected the log to be stored in the legacy table, got %d rows", count)
+	}
+}
+
+func TestColumn_Validate(t *testing.T) {
+	cases := []struct {
+		col Column
+		ok  bool
+	}{
+		{Column{Name: "user_id"}, true},
+		{Column{Name: "status", Type: "INT"}, true},
+		{Column{Name: "latency", Type: "double  precision"}, true},
+		{Column{Name: "at", Type: "timestamptz"}, true},
+		{Column{Name: "User"}, false},
+		{Column{Name: "user-id"}, false},
+		{Column{Name: ""}, false},
+		{Column{Name: "req_id"}, false},
+		{Column{Name: "raw"}, false},
+		{Column{Name: "body", Type: "jsonb"}, false},
+	}
+	for _, c := range cases {
+		if err := c.col.Validate(); (err == nil) != c.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", c.col, err, c.ok)
+		}
+	}
+
+	if err := ValidateColumns([]Column{{Name: "user_id"}, {Name: "status", Type: "int"}}); err != nil {
+		t.Errorf("ValidateColumns returned %v", err)
+	}
+	if err := ValidateColumns([]Column{{Name: "user_id"}, {Name: "user_id", Keys: []string{"uid"}}}); err == nil {
+		t.Errorf("expected ValidateColumns to reject duplicate names")
+	}
+
+	if got := (Column{Name: "status", Type: "int4"}).SQLType(); got != "integer" {
+		t.Errorf("SQLType() = %q, want integer", got)
+	}
+	if got := (Column{Name: "user_id"}).SQLType(); got != "text" {
+		t.Errorf("SQLType() = %q, want text", got)
+	}
+}
+
+func TestColumn_Value(t *testing.T) {
+	var m map[string]any
+	dec := json.NewDecoder(strings.NewReader(`{
+		"user_id": 12345678901234567, "tenant": "acme", "status": "404",
+		"ok": true, "ratio": 0.5, "at": "2025-01-02T03:04:05.000Z",
+		"big": 3000000000, "path": null, "uid": "u-1"
+	}`))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		col  Column
+		want any
+	}{
+		{Column{Name: "user_id"}, "12345678901234567"},
+		{Column{Name: "user_id", Type: "bigint"}, int64(12345678901234567)},
+		{Column{Name: "tenant_id", Keys: []string{"tenant_id", "tenant"}}, "acme"},
+		{Column{Name: "status", Type: "integer"}, int64(404)},
+		{Column{Name: "ok", Type: "boolean"}, true},
+		{Column{Name: "ratio", Type: "float8"}, 0.5},
+		{Column{Name: "at", Type: "timestamptz"}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Column{Name: "ok"}, "true"},
+		// Missing, null and unconvertible values are stored as NULL.
+		{Column{Name: "missing"}, nil},
+		{Column{Name: "path", Keys: []string{"path", "uid"}}, "u-1"},
+		{Column{Name: "tenant", Type: "bigint"}, nil},
+		{Column{Name: "big", Type: "integer"}, nil},
+		{Column{Name: "ratio", Type: "bigint"}, nil},
+	}
+	for _, c := range cases {
+		got := c.col.Value(m)
+		if tm, ok := got.(time.Time); ok {
+			if !tm.Equal(c.want.(time.Time)) {
+				t.Errorf("%+v: got %v, want %v", c.col, got, c.want)
+			}
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%+v: got %#v, want %#v", c.col, got, c.want)
+		}
+	}
+}
+
+func TestNew_RejectsInvalidColumn(t *testing.T) {
+	if _, _, err := New(nil, Config{Columns: []Column{{Name: "trace_id"}}}); err == nil {
+		t.Fatal("expected an error for a built-in column name")
+	}
+	if _, _, err := New(nil, Config{Columns: []Column{{Name: "tenant"}, {Name: "tenant"}}}); err == nil {
+		t.Fatal("expected an error for duplicate column names")
+	}
+}
+
+func TestPgcore_FillsPromotedColumns(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ensureLogsTable(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS user_id TEXT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS status INTEGER;`); err != nil {
+		t.Fatalf("failed to add columns: %v", err)
+	}
+
+	core, closeFn, err := New(db, Config{
+		Level: zapcore.InfoLevel,
+		Columns: []Column{
+			{Name: "user_id", Keys: []string{"user_id", "uid"}},
+			{Name: "status", Type: "integer"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("pgcore.New returned error: %v", err)
+	}
+
+	logger := zap.New(core)
+
+	const msg = "pgcore_test_promoted_columns"
+	logger.Info(msg, zap.String("uid", "u-42"), zap.Int("status", 503))
+	logger.Info(msg+"_bad", zap.String("status", "not a number"))
+
+	if err := closeFn(ctx); err != nil {
+		t.Logf("closeFn returned error (ignored): %v", err)
+	}
+
+	var userID sql.NullString
+	var status sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT user_id, status FROM logs WHERE raw->>'msg' = $1`, msg).Scan(&userID, &status); err != nil {
+		t.Fatalf("failed to query logs table: %v", err)
+	}
+	if userID.String != "u-42" || status.Int64 != 503 {
+		t.Fatalf("expected user_id=u-42 status=503, got %v %v", userID, status)
+	}
+
+	if err := db.QueryRowContext(ctx, `SELECT status FROM logs WHERE raw->>'msg' = $1`, msg+"_bad").Scan(&status); err != nil {
+		t.Fatalf("expected the line with a bad status to be stored: %v", err)
+	}
+	if status.Valid {
+		t.Fatalf("expected a NULL status, got %v", status.Int64)
+	}
+}
@@ -0,0 +1,68 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultFollowWindow is the default time a gap in the ids is waited for by
+// SafeID and FollowStart.
+const DefaultFollowWindow = time.Minute
+
+// safeIDSQL returns the id preceding the first gap of ($1, $2] whose next row
+// is more recent than $3 seconds, or $2 without such a gap.
+const safeIDSQL = `
+SELECT coalesce(min(prev), $2) FROM (
+    SELECT id, ts, coalesce(lag(id) OVER (ORDER BY id), $1) AS prev
+    FROM logs
+    WHERE id > $1 AND id <= $2
+) r
+WHERE id > prev + 1 AND ts > now() - $3::float8 * interval '1 second'`
+
+// followStartSQL returns the highest id of the rows older than $1 seconds.
+const followStartSQL = `
+SELECT coalesce(max(id), 0) FROM logs WHERE ts <= now() - $1::float8 * interval '1 second'`
+
+// SafeID returns the highest id c in [after, upTo] such that no row with an
+// id in (after, c] can still appear.
+//
+// Ids are reserved when rows are inserted but become visible when their
+// transaction commits: pgcore writes each batch in its own transaction, so a
+// batch can commit after a batch with higher ids. Following the table by the
+// highest id seen would skip it. A missing id is waited for while the row
+// following it is more recent than window (DefaultFollowWindow if zero or
+// negative); after that it is assumed rolled back.
+//
+// Followers query IDRange(c, upTo') on the next poll, so rows above c are
+// returned again: deduplicate them by id.
+func SafeID(ctx context.Context, db *sql.DB, after, upTo int64, window time.Duration) (int64, error) {
+	if upTo <= after {
+		return after, nil
+	}
+	if window <= 0 {
+		window = DefaultFollowWindow
+	}
+
+	var id int64
+	if err := db.QueryRowContext(ctx, safeIDSQL, after, upTo, window.Seconds()).Scan(&id); err != nil {
+		return 0, fmt.Errorf("query: safe id failed: %w", err)
+	}
+	return id, nil
+}
+
+// FollowStart returns the id to follow the table from, including the rows
+// still being committed: the SafeID of the rows logged within window, up to
+// upTo (usually MaxID).
+func FollowStart(ctx context.Context, db *sql.DB, upTo int64, window time.Duration) (int64, error) {
+	if window <= 0 {
+		window = DefaultFollowWindow
+	}
+
+	var floor int64
+	if err := db.QueryRowContext(ctx, followStartSQL, window.Seconds()).Scan(&floor); err != nil {
+		return 0, fmt.Errorf("query: follow start failed: %w", err)
+	}
+	return SafeID(ctx, db, min(floor, upTo), upTo, window)
+}
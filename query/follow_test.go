@@ -0,0 +1,82 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSafeID_WaitsForUncommittedRows(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS logs (
+    id      BIGSERIAL PRIMARY KEY,
+    ts      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    req_id  TEXT,
+    trace_id TEXT,
+    raw     JSONB NOT NULL
+);`); err != nil {
+		t.Fatalf("failed to create logs table: %v", err)
+	}
+
+	start, err := MaxID(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch reserves an id but commits after a later batch.
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO logs (raw) VALUES ('{"msg":"late"}')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO logs (raw) VALUES ('{"msg":"early"}')`); err != nil {
+		t.Fatal(err)
+	}
+
+	hi, err := MaxID(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if safe, err := SafeID(ctx, db, start, hi, time.Minute); err != nil || safe >= hi-1 {
+		t.Fatalf("expected SafeID before the uncommitted id, got %d (max %d): %v", safe, hi, err)
+	}
+	if got, err := FollowStart(ctx, db, hi, time.Minute); err != nil || got >= hi-1 {
+		t.Fatalf("expected FollowStart before the uncommitted id, got %d (max %d): %v", got, hi, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if safe, err := SafeID(ctx, db, start, hi, time.Minute); err != nil || safe != hi {
+		t.Fatalf("expected SafeID %d once committed, got %d: %v", hi, safe, err)
+	}
+
+	// A gap followed by old rows is assumed rolled back.
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO logs (raw) VALUES ('{"msg":"rolled back"}')`); err != nil {
+		t.Fatal(err)
+	}
+	_ = tx.Rollback()
+	if _, err := db.ExecContext(ctx, `INSERT INTO logs (ts, raw) VALUES (now() - interval '1 hour', '{"msg":"old"}')`); err != nil {
+		t.Fatal(err)
+	}
+	hi2, err := MaxID(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if safe, err := SafeID(ctx, db, hi, hi2, time.Minute); err != nil || safe != hi2 {
+		t.Fatalf("expected an old gap to be skipped, got %d, want %d: %v", safe, hi2, err)
+	}
+}
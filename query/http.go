@@ -0,0 +1,234 @@
+package query
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// MaxLimit is the largest page size accepted by Handler.
+const MaxLimit = 1000
+
+// ParseTime parses an absolute RFC 3339 time or a duration relative to now
+// ("15m" means 15 minutes ago).
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("query: invalid time %q (want RFC 3339 or a duration)", s)
+}
+
+// ParseCondition parses a field condition such as "user.id=42",
+// "duration_ms>=100", `status!="ok"` or "error~timeout". The value is decoded as JSON when
+// possible (number, bool, null, quoted string) and used as a plain string
+// otherwise. Path keys containing dots are double quoted (see SplitPath):
+// `"http.response.status_code">=500`.
+func ParseCondition(s string) (path string, op Op, value any, err error) {
+	idx := operatorIndex(s)
+	if idx <= 0 {
+		return "", "", nil, fmt.Errorf("query: invalid condition %q (want path<op>value)", s)
+	}
+
+	path, rest := s[:idx], s[idx:]
+	for _, o := range []Op{Ne, Lte, Gte, Eq, Lt, Gt, Contains} {
+		if strings.HasPrefix(rest, string(o)) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return "", "", nil, fmt.Errorf("query: invalid operator in condition %q", s)
+	}
+
+	return strings.TrimSpace(path), op, parseValue(strings.TrimSpace(rest[len(op):])), nil
+}
+
+// operatorIndex returns the index of the first operator character of s
+// outside double quoted path keys, or -1.
+func operatorIndex(s string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && strings.IndexByte("=!<>~", c) >= 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseValue decodes raw as a JSON scalar, falling back to the raw string.
+func parseValue(raw string) any {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return raw
+	}
+	switch v.(type) {
+	case map[string]any, []any:
+		return raw
+	}
+	return v
+}
+
+// FromValues builds a query from URL query parameters:
+//
+//	q                 search expression (see Parse)
+//	since, until      RFC 3339 time or duration ago ("1h")
+//	level             comma separated levels
+//	min_level         lowest level to return
+//	service, req_id, trace_id
+//	msg               message substring
+//	text              full-text search
+//	field             condition such as "user.id=42" (repeatable)
+//	limit, cursor     page size and continuation token
+//	order             "desc" (default) or "asc"
+func FromValues(v url.Values, now time.Time) (*Query, error) {
+	q := New()
+	if s := v.Get("q"); s != "" {
+		var err error
+		if q, err = Parse(s, now); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range []string{"since", "until"} {
+		s := v.Get(k)
+		if s == "" {
+			continue
+		}
+		t, err := ParseTime(s, now)
+		if err != nil {
+			return nil, err
+		}
+		if k == "since" {
+			q.Since(t)
+		} else {
+			q.Until(t)
+		}
+	}
+
+	if s := v.Get("level"); s != "" {
+		q.Level(strings.Split(s, ",")...)
+	}
+	if s := v.Get("min_level"); s != "" {
+		lvl, err := zapcore.ParseLevel(s)
+		if err != nil {
+			return nil, fmt.Errorf("query: %w", err)
+		}
+		q.MinLevel(lvl)
+	}
+	if s := v.Get("service"); s != "" {
+		q.Service(s)
+	}
+	if s := v.Get("req_id"); s != "" {
+		q.RequestID(s)
+	}
+	if s := v.Get("trace_id"); s != "" {
+		q.TraceID(s)
+	}
+	if s := v.Get("msg"); s != "" {
+		q.MessageContains(s)
+	}
+	if s := v.Get("text"); s != "" {
+		q.Text(s)
+	}
+	for _, s := range v["field"] {
+		path, op, value, err := ParseCondition(s)
+		if err != nil {
+			return nil, err
+		}
+		q.Field(path, op, value)
+	}
+
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > MaxLimit {
+			return nil, fmt.Errorf("query: limit must be between 1 and %d", MaxLimit)
+		}
+		q.Limit(n)
+	}
+	if s := v.Get("cursor"); s != "" {
+		c, err := ParseCursor(s)
+		if err != nil {
+			return nil, err
+		}
+		q.After(c)
+	}
+	switch v.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Ascending()
+	default:
+		return nil, fmt.Errorf("query: order must be asc or desc")
+	}
+
+	if _, _, err := q.SQL(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// pageResponse is the JSON body returned by Handler.
+type pageResponse struct {
+	Entries    []LogEntry `json:"entries"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Handler returns a read-only HTTP API over the logs table. GET requests are
+// filtered with the parameters documented on FromValues and answered with
+// {"entries": [...], "next_cursor": "..."}; pass next_cursor back as cursor to
+// get the next page.
+//
+// The handler exposes every log line: mount it behind authentication.
+func Handler(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		q, err := FromValues(r.URL.Query(), time.Now())
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		page, err := q.Run(r.Context(), db)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+
+		resp := pageResponse{Entries: page.Entries}
+		if page.Next != nil {
+			resp.NextCursor = page.Next.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// WriteError writes status and the JSON error body of Handler,
+// {"error": msg}.
+func WriteError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,109 @@
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		in    string
+		path  string
+		op    Op
+		value any
+	}{
+		{"user.id=u-1", "user.id", Eq, "u-1"},
+		{"latency_ms>=100", "latency_ms", Gte, json.Number("100")},
+		{`status!="ok"`, "status", Ne, "ok"},
+		{"cached=true", "cached", Eq, true},
+		{"n<2.5", "n", Lt, json.Number("2.5")},
+		{"x>y z", "x", Gt, "y z"},
+		{"err=null", "err", Eq, nil},
+		{"error~timeout", "error", Contains, "timeout"},
+		{`"http.response.status_code">=500`, `"http.response.status_code"`, Gte, json.Number("500")},
+		{`attrs."a=b"=1`, `attrs."a=b"`, Eq, json.Number("1")},
+	}
+	for _, tt := range tests {
+		path, op, value, err := ParseCondition(tt.in)
+		if err != nil {
+			t.Errorf("ParseCondition(%q) returned error: %v", tt.in, err)
+			continue
+		}
+		if path != tt.path || op != tt.op || value != tt.value {
+			t.Errorf("ParseCondition(%q) = %q %q %#v, want %q %q %#v", tt.in, path, op, value, tt.path, tt.op, tt.value)
+		}
+	}
+
+	for _, bad := range []string{"", "=1", "novalue", "a!1", "a^1"} {
+		if _, _, _, err := ParseCondition(bad); err == nil {
+			t.Errorf("ParseCondition(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got, err := ParseTime("15m", now); err != nil || !got.Equal(now.Add(-15*time.Minute)) {
+		t.Errorf("ParseTime(15m) = %v, %v", got, err)
+	}
+	if got, err := ParseTime("2025-01-01T10:00:00Z", now); err != nil || got.Hour() != 10 {
+		t.Errorf("ParseTime(RFC 3339) = %v, %v", got, err)
+	}
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Errorf("expected an error for an invalid time")
+	}
+}
+
+func TestFromValues(t *testing.T) {
+	v, _ := url.ParseQuery("since=1h&min_level=warn&service=api&field=user.id%3Du-1&limit=10&order=asc")
+	q, err := FromValues(v, time.Now())
+	if err != nil {
+		t.Fatalf("FromValues failed: %v", err)
+	}
+	stmt, args, _ := q.SQL()
+	if len(args) != 5 || !strings.Contains(stmt, "raw @> $4::jsonb") || !strings.HasSuffix(stmt, "ASC LIMIT 10") {
+		t.Errorf("unexpected query: %s %v", stmt, args)
+	}
+
+	v, _ = url.ParseQuery("q=" + url.QueryEscape("level>=error status>=500") + "&service=api")
+	q, err = FromValues(v, time.Now())
+	if err != nil {
+		t.Fatalf("FromValues with q failed: %v", err)
+	}
+	if _, args, _ := q.SQL(); len(args) != 4 {
+		t.Errorf("expected the expression and parameters combined, got %v", args)
+	}
+
+	for _, bad := range []string{"q=level~x", "since=nope", "min_level=loud", "limit=0", "limit=5000", "cursor=%21", "order=up", "field=a..b%3D1"} {
+		v, _ := url.ParseQuery(bad)
+		if _, err := FromValues(v, time.Now()); err == nil {
+			t.Errorf("FromValues(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestHandler_RejectsBadRequests(t *testing.T) {
+	h := Handler(nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") == "" {
+		t.Errorf("expected 405 with Allow header, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?limit=abc", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] == "" {
+		t.Errorf("expected a JSON error body, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,179 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Parse compiles a compact search expression into a query. The expression is
+// a list of terms separated by spaces, all of which must match:
+//
+//	level>=warn service=auth status>=500 since=2h msg~"timeout"
+//
+// Terms are conditions on JSON paths (see ParseCondition) with a few special
+// keys:
+//
+//	since, until       RFC 3339 time or duration ago (only "=")
+//	level              compared by severity ("level>=warn")
+//	service            service name
+//	req_id, trace_id   matched on the indexed columns
+//	msg~text           message substring
+//
+// A term without operator is a full-text search word. Values containing
+// spaces are double quoted; quoting a number ("500") compares it as a string.
+// Keys containing dots are double quoted too: "http.request.method"=GET.
+func Parse(expr string, now time.Time) (*Query, error) {
+	terms, err := splitTerms(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	q := New()
+	var words []string
+	for _, term := range terms {
+		if operatorIndex(term) < 0 {
+			words = append(words, unquote(term))
+			continue
+		}
+
+		path, op, value, err := ParseCondition(term)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.term(path, op, value, now); err != nil {
+			return nil, err
+		}
+	}
+	if len(words) > 0 {
+		q.Text(strings.Join(words, " "))
+	}
+
+	if _, _, err := q.SQL(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// term adds the condition of a parsed term, handling the special keys.
+func (q *Query) term(path string, op Op, value any, now time.Time) error {
+	s := ""
+	if value != nil {
+		s = fmt.Sprint(value)
+	}
+
+	switch {
+	case path == "since" || path == "until":
+		if op != Eq {
+			return fmt.Errorf("query: %s only supports =", path)
+		}
+		t, err := ParseTime(s, now)
+		if err != nil {
+			return err
+		}
+		if path == "since" {
+			q.Since(t)
+		} else {
+			q.Until(t)
+		}
+
+	case path == "level":
+		levels, err := compareLevels(op, s)
+		if err != nil {
+			return err
+		}
+		q.Level(levels...)
+
+	case path == "service" && op == Eq:
+		q.Service(s)
+	case path == "req_id" && op == Eq:
+		q.RequestID(s)
+	case path == "trace_id" && op == Eq:
+		q.TraceID(s)
+	case path == "msg" && op == Contains:
+		q.MessageContains(s)
+
+	default:
+		q.Field(path, op, value)
+	}
+	return nil
+}
+
+// compareLevels returns the level names l such that "l op name" holds.
+func compareLevels(op Op, name string) ([]string, error) {
+	ref, err := zapcore.ParseLevel(name)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	var levels []string
+	for l := zapcore.DebugLevel; l <= zapcore.FatalLevel; l++ {
+		var ok bool
+		switch op {
+		case Eq:
+			ok = l == ref
+		case Ne:
+			ok = l != ref
+		case Lt:
+			ok = l < ref
+		case Lte:
+			ok = l <= ref
+		case Gt:
+			ok = l > ref
+		case Gte:
+			ok = l >= ref
+		default:
+			return nil, fmt.Errorf("query: unsupported operator %q for level", op)
+		}
+		if ok {
+			levels = append(levels, l.String())
+		}
+	}
+	return levels, nil
+}
+
+// splitTerms splits expr on spaces outside double quotes. Quotes are kept so
+// that values can tell strings from numbers.
+func splitTerms(expr string) ([]string, error) {
+	var (
+		terms   []string
+		cur     strings.Builder
+		quoted  bool
+		escaped bool
+	)
+	for _, r := range expr {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			if cur.Len() > 0 {
+				terms = append(terms, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	if quoted {
+		return nil, fmt.Errorf("query: unterminated quote in %q", expr)
+	}
+	if cur.Len() > 0 {
+		terms = append(terms, cur.String())
+	}
+	return terms, nil
+}
+
+// unquote removes the double quotes around a full-text word.
+func unquote(s string) string {
+	if v, ok := parseValue(s).(string); ok {
+		return v
+	}
+	return s
+}
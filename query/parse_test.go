@@ -0,0 +1,107 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	q, err := Parse(`level>=warn service=auth status>=500 since=2h msg~"time out" req_id=r-1 code="42" connection refused`, now)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	stmt, args, err := q.SQL()
+	if err != nil {
+		t.Fatalf("SQL failed: %v", err)
+	}
+
+	want := "SELECT id, ts, req_id, trace_id, raw FROM logs WHERE raw->>'level' = ANY($1)" +
+		" AND raw->>'service' = $2" +
+		" AND jsonb_path_exists(raw, $3::jsonpath, jsonb_build_object('v', $4::jsonb))" +
+		" AND ts >= $5" +
+		" AND raw->>'msg' ILIKE $6" +
+		" AND req_id = $7" +
+		" AND (raw @> $8::jsonb AND raw->>'code' = $9)" +
+		" AND to_tsvector('simple', raw::text) @@ plainto_tsquery('simple', $10)" +
+		" ORDER BY ts DESC, id DESC LIMIT 100"
+	if stmt != want {
+		t.Errorf("unexpected SQL:\n got: %s\nwant: %s", stmt, want)
+	}
+
+	wantArgs := []any{
+		pq.Array([]string{"warn", "error", "dpanic", "panic", "fatal"}),
+		"auth",
+		`$."status" ? (@ >= $v)`,
+		"500",
+		now.Add(-2 * time.Hour),
+		"%time out%",
+		"r-1",
+		`{"code":"42"}`,
+		"42",
+		"connection refused",
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("unexpected args:\n got: %#v\nwant: %#v", args, wantArgs)
+	}
+}
+
+func TestParse_QuotedKeys(t *testing.T) {
+	q, err := Parse(`"http.request.method"=GET "a=b"`, time.Now())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	_, args, _ := q.SQL()
+	if len(args) != 3 || args[0] != `{"http.request.method":"GET"}` || args[1] != "GET" || args[2] != "a=b" {
+		t.Errorf("expected a flat dotted key and a quoted word, got %v", args)
+	}
+}
+
+func TestParse_SpecialKeysFallBackToFields(t *testing.T) {
+	q, err := Parse(`service!=auth error~timeout level<info`, time.Now())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	stmt, args, _ := q.SQL()
+
+	if !strings.Contains(stmt, "NOT (raw @> $1::jsonb AND raw->>'service' = $2)") || args[0] != `{"service":"auth"}` {
+		t.Errorf("expected service!= as a field condition: %s %v", stmt, args)
+	}
+	if !strings.Contains(stmt, "raw #>> $3 ILIKE $4") || args[3] != "%timeout%" {
+		t.Errorf("expected ~ as a contains condition: %s %v", stmt, args)
+	}
+	if !reflect.DeepEqual(args[4], pq.Array([]string{"debug"})) {
+		t.Errorf("expected level<info to keep debug only, got %v", args[4])
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, expr := range []string{
+		`msg~"unterminated`,
+		`since>1h`,
+		`since=yesterday`,
+		`level>=loud`,
+		`level~warn`,
+		`a..b=1`,
+	} {
+		if _, err := Parse(expr, time.Now()); err == nil {
+			t.Errorf("Parse(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestSplitTerms(t *testing.T) {
+	got, err := splitTerms(`  a=1   msg~"x \"y\" z"  word `)
+	if err != nil {
+		t.Fatalf("splitTerms failed: %v", err)
+	}
+	want := []string{"a=1", `msg~"x \"y\" z"`, "word"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitTerms = %q, want %q", got, want)
+	}
+}
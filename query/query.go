@@ -0,0 +1,633 @@
+// Package query reads logs back from the Postgres logs table.
+//
+// A Query is built with typed filters (time range, level, service, request and
+// trace ids, message, JSON fields, full-text) and returns LogEntry pages
+// ordered by (ts, id), with opaque cursors for pagination:
+//
+//	page, err := query.New().
+//		Since(time.Now().Add(-time.Hour)).
+//		MinLevel(zapcore.WarnLevel).
+//		Field("user.id", query.Eq, "u-1").
+//		Limit(50).
+//		Run(ctx, db)
+package query
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap/zapcore"
+)
+
+// isoLayout is the layout of zapcore.ISO8601TimeEncoder, used by pgcore.
+const isoLayout = "2006-01-02T15:04:05.000Z0700"
+
+// DefaultLimit is the page size used when none is set.
+const DefaultLimit = 100
+
+// ErrInvalidCursor is returned by ParseCursor for malformed cursors.
+var ErrInvalidCursor = errors.New("query: invalid cursor")
+
+// Op is a comparison operator used by Field.
+type Op string
+
+// Supported comparison operators.
+const (
+	Eq  Op = "="
+	Ne  Op = "!="
+	Lt  Op = "<"
+	Lte Op = "<="
+	Gt  Op = ">"
+	Gte Op = ">="
+
+	// Contains matches values whose text contains the given string,
+	// case-insensitively.
+	Contains Op = "~"
+)
+
+// valid reports whether op is a supported operator.
+func (op Op) valid() bool {
+	switch op {
+	case Eq, Ne, Lt, Lte, Gt, Gte, Contains:
+		return true
+	}
+	return false
+}
+
+// LogEntry is a row of the logs table with the common zap keys decoded.
+type LogEntry struct {
+	ID int64     `json:"id"`
+	TS time.Time `json:"ts"`
+
+	// Time is the time of the log call read from the payload, which precedes
+	// TS (the insertion time) by up to the batching delay. It is zero if the
+	// payload has no valid "ts".
+	Time time.Time `json:"time"`
+
+	ReqID      string         `json:"req_id,omitempty"`
+	TraceID    string         `json:"trace_id,omitempty"`
+	Level      string         `json:"level,omitempty"`
+	Message    string         `json:"msg,omitempty"`
+	Service    string         `json:"service,omitempty"`
+	Caller     string         `json:"caller,omitempty"`
+	Stacktrace string         `json:"stacktrace,omitempty"`
+	Fields     map[string]any `json:"fields"`
+
+	// Raw is the JSON payload as stored.
+	Raw json.RawMessage `json:"-"`
+}
+
+// LoggedAt returns Time, or TS when the payload has no timestamp.
+func (e LogEntry) LoggedAt() time.Time {
+	if e.Time.IsZero() {
+		return e.TS
+	}
+	return e.Time
+}
+
+// Cursor points at a row of the logs table. Pages continue strictly after it.
+type Cursor struct {
+	TS time.Time
+	ID int64
+}
+
+// String encodes c as an opaque URL safe token.
+func (c Cursor) String() string {
+	s := strconv.FormatInt(c.TS.UnixMicro(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ParseCursor decodes a token produced by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	micros, err1 := strconv.ParseInt(ts, 10, 64)
+	n, err2 := strconv.ParseInt(id, 10, 64)
+	if err1 != nil || err2 != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{TS: time.UnixMicro(micros).UTC(), ID: n}, nil
+}
+
+// Page is a page of results. Next is set when more rows may follow.
+type Page struct {
+	Entries []LogEntry `json:"entries"`
+	Next    *Cursor    `json:"-"`
+}
+
+// Query is a filter over the logs table. The zero value is not usable; use New.
+// Builder methods modify and return the receiver.
+type Query struct {
+	where []string
+	args  []any
+	err   error
+
+	after     *Cursor
+	limit     int
+	ascending bool
+	byID      bool
+}
+
+// New returns a query matching every row, newest first.
+func New() *Query {
+	return &Query{limit: DefaultLimit}
+}
+
+// add appends a condition, with its "$?" placeholders numbered after the
+// existing arguments.
+func (q *Query) add(cond string, args ...any) *Query {
+	for _, a := range args {
+		q.args = append(q.args, a)
+		cond = strings.Replace(cond, "$?", "$"+strconv.Itoa(len(q.args)), 1)
+	}
+	q.where = append(q.where, cond)
+	return q
+}
+
+// fail records the first builder error, returned by SQL and Run.
+func (q *Query) fail(err error) *Query {
+	if q.err == nil {
+		q.err = err
+	}
+	return q
+}
+
+// Since keeps rows logged at or after t.
+func (q *Query) Since(t time.Time) *Query {
+	return q.add("ts >= $?", t)
+}
+
+// Until keeps rows logged before t.
+func (q *Query) Until(t time.Time) *Query {
+	return q.add("ts < $?", t)
+}
+
+// Level keeps rows with one of the given levels ("info", "error", ...).
+func (q *Query) Level(levels ...string) *Query {
+	lower := make([]string, len(levels))
+	for i, l := range levels {
+		lower[i] = strings.ToLower(l)
+	}
+	return q.add("raw->>'level' = ANY($?)", pq.Array(lower))
+}
+
+// MinLevel keeps rows logged at lvl or above.
+func (q *Query) MinLevel(lvl zapcore.Level) *Query {
+	var levels []string
+	for l := lvl; l <= zapcore.FatalLevel; l++ {
+		levels = append(levels, l.String())
+	}
+	return q.Level(levels...)
+}
+
+// Service keeps rows of the given service.
+func (q *Query) Service(name string) *Query {
+	return q.add("raw->>'service' = $?", name)
+}
+
+// RequestID keeps rows with the given request ID (req_id column).
+func (q *Query) RequestID(id string) *Query {
+	return q.add("req_id = $?", id)
+}
+
+// TraceID keeps rows with the given W3C trace ID (trace_id column).
+func (q *Query) TraceID(id string) *Query {
+	return q.add("trace_id = $?", id)
+}
+
+// MessageContains keeps rows whose message contains s, case-insensitively.
+func (q *Query) MessageContains(s string) *Query {
+	return q.add(`raw->>'msg' ILIKE $?`, "%"+escapeLike(s)+"%")
+}
+
+// Text keeps rows whose payload matches the words of s (full-text search with
+// the "simple" configuration, all words required).
+func (q *Query) Text(s string) *Query {
+	return q.add("to_tsvector('simple', raw::text) @@ plainto_tsquery('simple', $?)", s)
+}
+
+// Field compares the JSON value at path (dot separated keys, e.g. "user.id")
+// with value, which may be a string, number, bool or nil. Eq and Ne compare
+// values of the same JSON type; ordering operators compare numbers with
+// numbers and strings with strings; Contains matches the text of any value.
+// Rows lacking the key never match, except for Ne.
+//
+// Eq can use a GIN index on raw and, for strings, an expression index on
+// FieldExpr(path) (see betterlogs.RawIndex and betterlogs.FieldIndex).
+func (q *Query) Field(path string, op Op, value any) *Query {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return q.fail(err)
+	}
+	if !op.valid() {
+		return q.fail(fmt.Errorf("query: unsupported operator %q", op))
+	}
+	if op == Contains {
+		return q.add("raw #>> $? ILIKE $?", pq.Array(keys), "%"+escapeLike(fmt.Sprint(value))+"%")
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return q.fail(fmt.Errorf("query: field %q: %w", path, err))
+	}
+
+	if op == Eq || op == Ne {
+		// Containment can use a GIN index on raw.
+		doc, _ := json.Marshal(nest(keys, json.RawMessage(v)))
+		cond, args := "raw @> $?::jsonb", []any{string(doc)}
+
+		// Strings are also compared with the text of the field, which an
+		// expression index on FieldExpr(path) can serve.
+		if s, ok := value.(string); ok {
+			cond = "(" + cond + " AND " + fieldExpr(keys) + " = $?)"
+			args = append(args, s)
+		}
+		if op == Eq {
+			return q.add(cond, args...)
+		}
+		return q.add("NOT "+cond, args...)
+	}
+
+	jp := "$"
+	for _, k := range keys {
+		jp += "." + strconv.Quote(k)
+	}
+	jp += " ? (@ " + string(op) + " $v)"
+	return q.add("jsonb_path_exists(raw, $?::jsonpath, jsonb_build_object('v', $?::jsonb))", jp, string(v))
+}
+
+// FieldExpr returns the SQL expression of the text of the payload field at
+// path, e.g. raw->'user'->>'id' for "user.id". Field compares strings with
+// it, as do Service and Level for their keys: an expression index on it
+// serves those filters.
+func FieldExpr(path string) (string, error) {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return "", err
+	}
+	return fieldExpr(keys), nil
+}
+
+// fieldExpr returns the FieldExpr of keys. Keys are inlined as literals, so
+// that the planner matches the expression with the one of an index.
+func fieldExpr(keys []string) string {
+	expr := "raw"
+	for i, k := range keys {
+		op := "->"
+		if i == len(keys)-1 {
+			op = "->>"
+		}
+		expr += op + pq.QuoteLiteral(k)
+	}
+	return expr
+}
+
+// After continues the query strictly after c (in the query order).
+func (q *Query) After(c Cursor) *Query {
+	q.after = &c
+	return q
+}
+
+// IDRange keeps rows with after < id <= upTo, ordered by id (insertion order)
+// instead of (ts, id). It is meant to follow the table as rows are inserted
+// (see MaxID); the cursor is ignored.
+func (q *Query) IDRange(after, upTo int64) *Query {
+	q.byID = true
+	return q.add("id > $? AND id <= $?", after, upTo)
+}
+
+// Limit sets the page size. Zero or negative values use DefaultLimit.
+func (q *Query) Limit(n int) *Query {
+	if n <= 0 {
+		n = DefaultLimit
+	}
+	q.limit = n
+	return q
+}
+
+// Ascending returns rows oldest first instead of newest first.
+func (q *Query) Ascending() *Query {
+	q.ascending = true
+	return q
+}
+
+// whereClause returns the WHERE clause (possibly empty) and its arguments,
+// including the cursor condition.
+func (q *Query) whereClause(withCursor bool) (string, []any) {
+	where := append([]string(nil), q.where...)
+	args := append([]any(nil), q.args...)
+
+	if withCursor && q.after != nil && !q.byID {
+		cmp := "<"
+		if q.ascending {
+			cmp = ">"
+		}
+		args = append(args, q.after.TS, q.after.ID)
+		where = append(where, fmt.Sprintf("(ts, id) %s ($%d, $%d)", cmp, len(args)-1, len(args)))
+	}
+
+	if len(where) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// SQL returns the SELECT statement of the query and its arguments.
+func (q *Query) SQL() (string, []any, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+
+	where, args := q.whereClause(true)
+	order := "DESC"
+	if q.ascending {
+		order = "ASC"
+	}
+	orderBy := " ORDER BY ts " + order + ", id " + order
+	if q.byID {
+		orderBy = " ORDER BY id " + order
+	}
+	stmt := "SELECT id, ts, req_id, trace_id, raw FROM logs" + where + orderBy +
+		" LIMIT " + strconv.Itoa(q.limit)
+	return stmt, args, nil
+}
+
+// Run executes the query and returns a page of entries.
+func (q *Query) Run(ctx context.Context, db *sql.DB) (Page, error) {
+	stmt, args, err := q.SQL()
+	if err != nil {
+		return Page{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return Page{}, fmt.Errorf("query: select failed: %w", err)
+	}
+	defer rows.Close()
+
+	page := Page{Entries: []LogEntry{}}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return Page{}, fmt.Errorf("query: reading rows failed: %w", err)
+	}
+
+	if n := len(page.Entries); n == q.limit {
+		last := page.Entries[n-1]
+		page.Next = &Cursor{TS: last.TS, ID: last.ID}
+	}
+	return page, nil
+}
+
+// Count returns the number of rows matching the query, ignoring the cursor
+// and the limit.
+func (q *Query) Count(ctx context.Context, db *sql.DB) (int64, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+
+	where, args := q.whereClause(false)
+	var n int64
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM logs"+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("query: count failed: %w", err)
+	}
+	return n, nil
+}
+
+// MaxID returns the highest id of the logs table, or 0 when it is empty.
+func MaxID(ctx context.Context, db *sql.DB) (int64, error) {
+	var id int64
+	if err := db.QueryRowContext(ctx, "SELECT coalesce(max(id), 0) FROM logs").Scan(&id); err != nil {
+		return 0, fmt.Errorf("query: max id failed: %w", err)
+	}
+	return id, nil
+}
+
+// Group is the number of rows sharing a value, as returned by GroupBy.
+type Group struct {
+	// Value is the grouped value as text; Null is set for rows lacking it.
+	Value string `json:"value"`
+	Null  bool   `json:"null,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// columns maps payload keys to the indexed columns holding them.
+var columns = map[string]string{
+	"req_id":   "req_id",
+	"trace_id": "trace_id",
+}
+
+// GroupBy counts the rows matching the query per value of the JSON path (the
+// req_id and trace_id columns are used for those keys). Groups are sorted by
+// decreasing count, at most the query limit of them. The cursor is ignored.
+func (q *Query) GroupBy(ctx context.Context, db *sql.DB, path string) ([]Group, error) {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	where, args := q.whereClause(false)
+	expr, ok := columns[path]
+	if !ok {
+		args = append(args, pq.Array(keys))
+		expr = "raw #>> $" + strconv.Itoa(len(args))
+	}
+	stmt := "SELECT " + expr + ", count(*) FROM logs" + where +
+		" GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT " + strconv.Itoa(q.limit)
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: group by failed: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		var (
+			v sql.NullString
+			g Group
+		)
+		if err := rows.Scan(&v, &g.Count); err != nil {
+			return nil, fmt.Errorf("query: scan failed: %w", err)
+		}
+		g.Value, g.Null = v.String, !v.Valid
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query: reading rows failed: %w", err)
+	}
+	return groups, nil
+}
+
+// Bucket is the number of rows logged in [Start, Start+interval), as returned
+// by Histogram.
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// Histogram counts the rows matching the query per time interval (on the ts
+// column). It returns the most recent buckets holding rows, at most the query
+// limit of them, oldest first. The cursor is ignored.
+func (q *Query) Histogram(ctx context.Context, db *sql.DB, interval time.Duration) ([]Bucket, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("query: histogram interval must be at least 1s")
+	}
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	where, args := q.whereClause(false)
+	args = append(args, interval.Seconds())
+	n := "$" + strconv.Itoa(len(args))
+	stmt := "SELECT to_timestamp(floor(extract(epoch FROM ts) / " + n + ") * " + n + "), count(*) FROM logs" +
+		where + " GROUP BY 1 ORDER BY 1 DESC LIMIT " + strconv.Itoa(q.limit)
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: histogram failed: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []Bucket{}
+	for rows.Next() {
+		var b Bucket
+		if err := rows.Scan(&b.Start, &b.Count); err != nil {
+			return nil, fmt.Errorf("query: scan failed: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query: reading rows failed: %w", err)
+	}
+
+	for i, j := 0, len(buckets)-1; i < j; i, j = i+1, j-1 {
+		buckets[i], buckets[j] = buckets[j], buckets[i]
+	}
+	return buckets, nil
+}
+
+// scanEntry reads a row selected by SQL into a LogEntry.
+func scanEntry(rows *sql.Rows) (LogEntry, error) {
+	var (
+		e              LogEntry
+		reqID, traceID sql.NullString
+		raw            []byte
+	)
+	if err := rows.Scan(&e.ID, &e.TS, &reqID, &traceID, &raw); err != nil {
+		return LogEntry{}, fmt.Errorf("query: scan failed: %w", err)
+	}
+	e.ReqID, e.TraceID = reqID.String, traceID.String
+	if err := e.decode(raw); err != nil {
+		return LogEntry{}, err
+	}
+	return e, nil
+}
+
+// decode fills the payload related fields of e from the raw JSON.
+func (e *LogEntry) decode(raw []byte) error {
+	e.Raw = json.RawMessage(raw)
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&e.Fields); err != nil {
+		return fmt.Errorf("query: decoding log %d failed: %w", e.ID, err)
+	}
+
+	e.Level, _ = e.Fields["level"].(string)
+	e.Message, _ = e.Fields["msg"].(string)
+	e.Service, _ = e.Fields["service"].(string)
+	e.Caller, _ = e.Fields["caller"].(string)
+	e.Stacktrace, _ = e.Fields["stack"].(string)
+	if ts, ok := e.Fields["ts"].(string); ok {
+		e.Time, _ = time.Parse(isoLayout, ts)
+	}
+	return nil
+}
+
+// SplitPath splits a dot separated JSON path into keys. A key containing dots,
+// such as the flat OpenTelemetry key "http.request.method", is written as a
+// double quoted segment: `"http.request.method"` or `attributes."url.path"`.
+func SplitPath(path string) ([]string, error) {
+	var keys []string
+	for rest := path; ; {
+		var key string
+		if strings.HasPrefix(rest, `"`) {
+			end := closingQuote(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("query: unterminated quote in field path %q", path)
+			}
+			if err := json.Unmarshal([]byte(rest[:end+1]), &key); err != nil {
+				return nil, fmt.Errorf("query: invalid quoted key in field path %q", path)
+			}
+			rest = rest[end+1:]
+			if rest != "" && rest[0] != '.' {
+				return nil, fmt.Errorf("query: invalid field path %q", path)
+			}
+		} else {
+			i := strings.IndexByte(rest, '.')
+			if i < 0 {
+				i = len(rest)
+			}
+			key, rest = rest[:i], rest[i:]
+			if key == "" || strings.Contains(key, `"`) {
+				return nil, fmt.Errorf("query: invalid field path %q", path)
+			}
+		}
+		keys = append(keys, key)
+
+		if rest == "" {
+			return keys, nil
+		}
+		rest = rest[1:] // the dot
+	}
+}
+
+// closingQuote returns the index of the quote closing the double quoted string
+// at the start of s, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// nest wraps v into objects along keys: {"a":{"b":v}}.
+func nest(keys []string, v any) any {
+	for i := len(keys) - 1; i >= 0; i-- {
+		v = map[string]any{keys[i]: v}
+	}
+	return v
+}
+
+// escapeLike escapes the LIKE wildcards of s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,45 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+)
+
+// Trace returns every row sharing the request ID or the trace ID (either may
+// be empty), across all services, ordered by the time of the log call.
+func Trace(ctx context.Context, db *sql.DB, reqID, traceID string) ([]LogEntry, error) {
+	q := New().Ascending().Limit(MaxLimit)
+	switch {
+	case reqID != "" && traceID != "":
+		q.add("(req_id = $? OR trace_id = $?)", reqID, traceID)
+	case reqID != "":
+		q.RequestID(reqID)
+	case traceID != "":
+		q.TraceID(traceID)
+	default:
+		return nil, errors.New("query: Trace needs a request ID or a trace ID")
+	}
+
+	// Never nil: an unknown ID is an empty trace, encoded as [] in JSON.
+	entries := []LogEntry{}
+	for {
+		page, err := q.Run(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page.Entries...)
+		if page.Next == nil {
+			break
+		}
+		q.After(*page.Next)
+	}
+
+	// Rows are inserted in batches per process: the payload time orders lines
+	// of different services better than the insertion time.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LoggedAt().Before(entries[j].LoggedAt())
+	})
+	return entries, nil
+}
@@ -0,0 +1,63 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTrace_RequiresAnID(t *testing.T) {
+	if _, err := Trace(context.Background(), nil, "", ""); err == nil {
+		t.Errorf("expected an error without request or trace ID")
+	}
+}
+
+func TestTrace_OrdersByLogTime(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The billing batch was inserted first but logged last.
+	const seed = `
+CREATE TABLE IF NOT EXISTS logs (
+    id      BIGSERIAL PRIMARY KEY,
+    ts      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    req_id  TEXT,
+    trace_id TEXT,
+    raw     JSONB NOT NULL
+);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS trace_id TEXT;
+DELETE FROM logs;
+INSERT INTO logs (ts, req_id, trace_id, raw) VALUES
+    ('2025-01-01T00:00:01Z', NULL,  't-1', '{"ts":"2025-01-01T00:00:00.300Z","service":"billing","msg":"charged"}'),
+    ('2025-01-01T00:00:02Z', 'r-1', 't-1', '{"ts":"2025-01-01T00:00:00.100Z","service":"api","msg":"start"}'),
+    ('2025-01-01T00:00:02Z', 'r-1', 't-1', '{"ts":"2025-01-01T00:00:00.500Z","service":"api","msg":"http_request"}'),
+    ('2025-01-01T00:00:02Z', 'r-2', 't-2', '{"ts":"2025-01-01T00:00:00.200Z","service":"api","msg":"other"}');
+`
+	if _, err := db.ExecContext(ctx, seed); err != nil {
+		t.Fatalf("failed to seed logs: %v", err)
+	}
+
+	entries, err := Trace(ctx, db, "r-1", "t-1")
+	if err != nil {
+		t.Fatalf("Trace failed: %v", err)
+	}
+
+	var msgs []string
+	for _, e := range entries {
+		msgs = append(msgs, e.Message)
+	}
+	if len(msgs) != 3 || msgs[0] != "start" || msgs[1] != "charged" || msgs[2] != "http_request" {
+		t.Errorf("unexpected trace order: %v", msgs)
+	}
+
+	unknown, err := Trace(ctx, db, "", "t-unknown")
+	if err != nil {
+		t.Fatalf("Trace failed: %v", err)
+	}
+	if unknown == nil || len(unknown) != 0 {
+		t.Errorf("expected an empty non-nil slice for an unknown trace, got %#v", unknown)
+	}
+}
@@ -3,32 +3,166 @@ package betterlogs
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/ZiplEix/better-logs/pgcore"
+	"github.com/lib/pq"
 )
 
-const logsTableDDL = `
-CREATE TABLE IF NOT EXISTS logs (
+// LogsTableDDL returns the statements creating the logs table named table,
+// and its indexes named idx_<table>_<column>, if they don't already exist,
+// then adding the promoted columns cols. EnsureLogsTable runs it for "logs";
+// archive.Import uses it for the tables it creates. The columns must be
+// valid (see pgcore.ValidateColumns).
+func LogsTableDDL(table string, cols ...pgcore.Column) string {
+	name := pq.QuoteIdentifier(table)
+	index := func(column string) string {
+		return pq.QuoteIdentifier("idx_" + table + "_" + column)
+	}
+
+	return `
+CREATE TABLE IF NOT EXISTS ` + name + ` (
     id      BIGSERIAL PRIMARY KEY,
     ts      TIMESTAMPTZ NOT NULL DEFAULT now(),
     req_id  TEXT,
+    trace_id TEXT,
     raw     JSONB NOT NULL
 );
 
-CREATE INDEX IF NOT EXISTS idx_logs_ts ON logs (ts DESC);
-CREATE INDEX IF NOT EXISTS idx_logs_req_id ON logs (req_id);
-`
+-- Tables created by older versions lack the trace_id column.
+ALTER TABLE ` + name + ` ADD COLUMN IF NOT EXISTS trace_id TEXT;
+
+CREATE INDEX IF NOT EXISTS ` + index("ts") + ` ON ` + name + ` (ts DESC);
+CREATE INDEX IF NOT EXISTS ` + index("req_id") + ` ON ` + name + ` (req_id);
+CREATE INDEX IF NOT EXISTS ` + index("trace_id") + ` ON ` + name + ` (trace_id);
+` + columnsDDL(table, cols)
+}
 
 // EnsureLogsTable creates the logs table (and indexes) if they don't already exist.
 // It is safe to call multiple times.
-func EnsureLogsTable(ctx context.Context, db *sql.DB) error {
+//
+// The promoted columns cols (see pgcore.Config.Columns) are added to the
+// table, with an index named idx_logs_<name> when Index is set. Existing
+// columns are left untouched. Building an index blocks writes to the table:
+// on a large live table, clear Index and build ColumnIndex with CreateIndexes
+// and IndexOptions.Concurrently instead.
+func EnsureLogsTable(ctx context.Context, db *sql.DB, cols ...pgcore.Column) error {
 	if db == nil {
 		return fmt.Errorf("better-logs: db is nil in EnsureLogsTable")
 	}
+	if err := pgcore.ValidateColumns(cols); err != nil {
+		return fmt.Errorf("better-logs: %w", err)
+	}
 
 	// ExecContext can run multiple statements in one string for Postgres.
-	if _, err := db.ExecContext(ctx, logsTableDDL); err != nil {
+	if _, err := db.ExecContext(ctx, LogsTableDDL("logs", cols...)); err != nil {
 		return fmt.Errorf("better-logs: creating logs table failed: %w", err)
 	}
 
 	return nil
 }
+
+// columnsDDL returns the statements adding the promoted columns cols to
+// table.
+func columnsDDL(table string, cols []pgcore.Column) string {
+	var b strings.Builder
+	for _, c := range cols {
+		name := pq.QuoteIdentifier(c.Name)
+		fmt.Fprintf(&b, "ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;\n", pq.QuoteIdentifier(table), name, c.SQLType())
+		if c.Index {
+			fmt.Fprintf(&b, "CREATE INDEX IF NOT EXISTS %s ON %s (%s);\n", pq.QuoteIdentifier("idx_"+table+"_"+c.Name), pq.QuoteIdentifier(table), name)
+		}
+	}
+	return b.String()
+}
+
+// ErrInvalidSchema is wrapped by the errors ValidateLogsTable returns when the
+// logs table is missing or lacks a column.
+var ErrInvalidSchema = errors.New("better-logs: invalid logs table")
+
+// logsColumn is a column of the logs table with its type as printed by
+// format_type.
+type logsColumn struct {
+	name, typ string
+
+	// optional columns may be missing from tables created by older
+	// versions, which pgcore keeps writing to without them.
+	optional bool
+}
+
+// logsColumns are the columns of the logs table read or written by the
+// library.
+var logsColumns = []logsColumn{
+	{name: "id", typ: "bigint"},
+	{name: "ts", typ: "timestamp with time zone"},
+	{name: "req_id", typ: "text"},
+	{name: "trace_id", typ: "text", optional: true},
+	{name: "raw", typ: "jsonb"},
+}
+
+const logsColumnsSQL = `
+SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+FROM pg_attribute a
+WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+`
+
+// ValidateLogsTable checks that the logs table exists with the columns and
+// types created by EnsureLogsTable, including the promoted columns cols.
+// Schema problems are reported as errors wrapping ErrInvalidSchema.
+//
+// The trace_id column may be missing from tables created before it was
+// added: pgcore then stores logs without it (see pgcore.New).
+func ValidateLogsTable(ctx context.Context, db *sql.DB, cols ...pgcore.Column) error {
+	if db == nil {
+		return fmt.Errorf("better-logs: db is nil in ValidateLogsTable")
+	}
+	if err := pgcore.ValidateColumns(cols); err != nil {
+		return fmt.Errorf("better-logs: %w", err)
+	}
+
+	var table sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('logs')::text`).Scan(&table); err != nil {
+		return fmt.Errorf("better-logs: checking logs table failed: %w", err)
+	}
+	if !table.Valid {
+		return fmt.Errorf("%w: table does not exist (call EnsureLogsTable, run \"betterlogs migrate\" or set Config.PG.AutoMigrate)", ErrInvalidSchema)
+	}
+
+	rows, err := db.QueryContext(ctx, logsColumnsSQL, table.String)
+	if err != nil {
+		return fmt.Errorf("better-logs: checking logs table failed: %w", err)
+	}
+	defer rows.Close()
+
+	types := map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return fmt.Errorf("better-logs: checking logs table failed: %w", err)
+		}
+		types[name] = typ
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("better-logs: checking logs table failed: %w", err)
+	}
+
+	expected := append([]logsColumn(nil), logsColumns...)
+	for _, c := range cols {
+		expected = append(expected, logsColumn{name: c.Name, typ: c.SQLType()})
+	}
+	for _, c := range expected {
+		typ, ok := types[c.name]
+		if !ok && c.optional {
+			continue
+		}
+		if !ok {
+			return fmt.Errorf("%w: column %s is missing (run EnsureLogsTable or \"betterlogs migrate\" to upgrade it)", ErrInvalidSchema, c.name)
+		}
+		if typ != c.typ {
+			return fmt.Errorf("%w: column %s is %s, expected %s", ErrInvalidSchema, c.name, typ, c.typ)
+		}
+	}
+	return nil
+}
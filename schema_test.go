@@ -3,9 +3,13 @@ package betterlogs
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/ZiplEix/better-logs/pgcore"
 )
 
 func getTestDB(t *testing.T) *sql.DB {
@@ -97,3 +101,124 @@ func TestEnsureLogsTable_NilDB(t *testing.T) {
 		t.Fatalf("expected error when db is nil, got nil")
 	}
 }
+
+func TestLogsTableDDL(t *testing.T) {
+	ddl := LogsTableDDL("logs_2025_01", pgcore.Column{Name: "status", Type: "int", Index: true})
+	for _, want := range []string{
+		`CREATE TABLE IF NOT EXISTS "logs_2025_01" (`,
+		`ALTER TABLE "logs_2025_01" ADD COLUMN IF NOT EXISTS trace_id TEXT;`,
+		`CREATE INDEX IF NOT EXISTS "idx_logs_2025_01_req_id" ON "logs_2025_01" (req_id);`,
+		`ALTER TABLE "logs_2025_01" ADD COLUMN IF NOT EXISTS "status" integer;`,
+		`CREATE INDEX IF NOT EXISTS "idx_logs_2025_01_status" ON "logs_2025_01" ("status");`,
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("expected DDL to contain %q, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestEnsureLogsTable_RejectsDuplicateColumns(t *testing.T) {
+	// The columns are checked before connecting.
+	db, err := sql.Open("postgres", "postgres://localhost:1/none?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cols := []pgcore.Column{{Name: "user_id"}, {Name: "user_id", Type: "bigint"}}
+	if err := EnsureLogsTable(context.Background(), db, cols...); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("expected EnsureLogsTable to reject duplicate columns, got %v", err)
+	}
+	if err := ValidateLogsTable(context.Background(), db, cols...); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("expected ValidateLogsTable to reject duplicate columns, got %v", err)
+	}
+}
+
+func TestValidateLogsTable_NilDB(t *testing.T) {
+	if err := ValidateLogsTable(context.Background(), nil); err == nil {
+		t.Fatalf("expected error when db is nil, got nil")
+	}
+}
+
+func TestValidateLogsTable_ReportsMissingColumn(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := EnsureLogsTable(ctx, db); err != nil {
+		t.Fatalf("EnsureLogsTable failed: %v", err)
+	}
+	if err := ValidateLogsTable(ctx, db); err != nil {
+		t.Fatalf("expected a migrated table to be valid, got %v", err)
+	}
+
+	// A table created by an older version has no trace_id column: pgcore
+	// still writes to it, so it is valid.
+	if _, err := db.ExecContext(ctx, `ALTER TABLE logs DROP COLUMN trace_id`); err != nil {
+		t.Fatalf("failed to drop column: %v", err)
+	}
+	if err := ValidateLogsTable(ctx, db); err != nil {
+		t.Errorf("expected a legacy table without trace_id to be valid, got %v", err)
+	}
+
+	err := ValidateLogsTable(ctx, db, pgcore.Column{Name: "validate_missing"})
+	if !errors.Is(err, ErrInvalidSchema) || !strings.Contains(err.Error(), "validate_missing") {
+		t.Errorf("expected ErrInvalidSchema about validate_missing, got %v", err)
+	}
+
+	if err := EnsureLogsTable(ctx, db); err != nil {
+		t.Fatalf("EnsureLogsTable failed: %v", err)
+	}
+	if err := ValidateLogsTable(ctx, db); err != nil {
+		t.Errorf("expected the upgraded table to be valid, got %v", err)
+	}
+}
+
+func TestEnsureLogsTable_PromotedColumns(t *testing.T) {
+	cols := []pgcore.Column{
+		{Name: "user_id", Index: true},
+		{Name: "status", Type: "integer"},
+	}
+	if err := EnsureLogsTable(context.Background(), nil, cols...); err == nil {
+		t.Fatalf("expected error when db is nil, got nil")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := EnsureLogsTable(ctx, db); err != nil {
+		t.Fatalf("EnsureLogsTable failed: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `ALTER TABLE logs DROP COLUMN IF EXISTS user_id, DROP COLUMN IF EXISTS status`); err != nil {
+		t.Fatalf("failed to drop columns: %v", err)
+	}
+	err := ValidateLogsTable(ctx, db, cols...)
+	if !errors.Is(err, ErrInvalidSchema) || !strings.Contains(err.Error(), "user_id") {
+		t.Errorf("expected ErrInvalidSchema about user_id, got %v", err)
+	}
+
+	if err := EnsureLogsTable(ctx, db, cols...); err != nil {
+		t.Fatalf("EnsureLogsTable with columns failed: %v", err)
+	}
+	if err := ValidateLogsTable(ctx, db, cols...); err != nil {
+		t.Errorf("expected the migrated table to be valid, got %v", err)
+	}
+
+	var indexed bool
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('idx_logs_user_id') IS NOT NULL`).Scan(&indexed); err != nil {
+		t.Fatalf("failed to check index: %v", err)
+	}
+	if !indexed {
+		t.Errorf("expected idx_logs_user_id to be created")
+	}
+
+	err = ValidateLogsTable(ctx, db, pgcore.Column{Name: "status", Type: "bigint"})
+	if !errors.Is(err, ErrInvalidSchema) || !strings.Contains(err.Error(), "status") {
+		t.Errorf("expected ErrInvalidSchema about the status type, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+// Package stats reports the size and content of the Postgres logs table: row
+// counts, on-disk size, volume per day, service, level and message, and
+// index bloat estimates.
+//
+// A Report marshals to JSON for monitoring.
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ZiplEix/better-logs/query"
+)
+
+// Config controls Collect.
+type Config struct {
+	// Window is the period, ending now, covered by the per day, service,
+	// level and message counts. If zero or negative, 30 days is used.
+	Window time.Duration
+
+	// Top is the number of services and messages reported. If zero or
+	// negative, 10 is used.
+	Top int
+}
+
+// DefaultConfig returns a sane default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Window: 30 * 24 * time.Hour,
+		Top:    10,
+	}
+}
+
+// Report describes the logs table.
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// Rows is the exact number of rows; EstimatedRows the planner estimate.
+	Rows          int64 `json:"rows"`
+	EstimatedRows int64 `json:"estimated_rows"`
+
+	Size Size `json:"size"`
+
+	// Oldest and Newest are the extreme ts values, nil for an empty table.
+	Oldest *time.Time `json:"oldest"`
+	Newest *time.Time `json:"newest"`
+
+	// Since is the start of the window of the counts below.
+	Since time.Time `json:"since"`
+
+	PerDay      []Count `json:"per_day"`
+	PerService  []Count `json:"per_service"`
+	PerLevel    []Count `json:"per_level"`
+	TopMessages []Count `json:"top_messages"`
+
+	Indexes []Index `json:"indexes"`
+}
+
+// Size is the on-disk size of the table, in bytes.
+type Size struct {
+	// Table is the size of the main heap.
+	Table int64 `json:"table_bytes"`
+
+	// Indexes is the size of all the indexes.
+	Indexes int64 `json:"indexes_bytes"`
+
+	// TOAST is the size of the TOAST table and its index, holding the large
+	// raw payloads.
+	TOAST int64 `json:"toast_bytes"`
+
+	// Total includes the heap, indexes, TOAST and free space maps.
+	Total int64 `json:"total_bytes"`
+}
+
+// Count is the number of rows sharing a value. Value is "" and Null true when
+// the key is missing from the payload.
+type Count struct {
+	Value string `json:"value"`
+	Null  bool   `json:"null,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// Index describes an index of the table.
+type Index struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	Bytes  int64  `json:"bytes"`
+
+	// Scans is the number of scans using the index since the statistics were
+	// last reset. An index that is never scanned only slows inserts down.
+	Scans int64 `json:"scans"`
+
+	// BloatBytes estimates the space the index would free if rebuilt, and
+	// BloatRatio its share of Bytes. They are only estimated for B-tree
+	// indexes on plain columns once the table has been analyzed, and are
+	// nil otherwise.
+	BloatBytes *int64   `json:"bloat_bytes"`
+	BloatRatio *float64 `json:"bloat_ratio"`
+}
+
+// overviewSQL reads the row count, time range and sizes of the table.
+const overviewSQL = `
+SELECT
+    (SELECT count(*) FROM logs),
+    (SELECT min(ts) FROM logs),
+    (SELECT max(ts) FROM logs),
+    c.reltuples::bigint,
+    pg_relation_size(c.oid),
+    pg_indexes_size(c.oid),
+    COALESCE(pg_total_relation_size(NULLIF(c.reltoastrelid, 0)), 0),
+    pg_total_relation_size(c.oid)
+FROM pg_class c
+WHERE c.oid = 'logs'::regclass`
+
+// indexesSQL lists the indexes of the table with the average width of their
+// key columns, read from the planner statistics.
+const indexesSQL = `
+SELECT
+    i.relname,
+    am.amname,
+    pg_relation_size(i.oid),
+    COALESCE(s.idx_scan, 0),
+    GREATEST(t.reltuples, 0)::bigint,
+    x.indexprs IS NULL AND x.indpred IS NULL,
+    (SELECT sum(st.avg_width)
+     FROM pg_attribute a
+     JOIN pg_stats st ON st.schemaname = n.nspname AND st.tablename = t.relname AND st.attname = a.attname
+     WHERE a.attrelid = t.oid AND a.attnum = ANY (x.indkey)),
+    current_setting('block_size')::bigint
+FROM pg_index x
+JOIN pg_class i ON i.oid = x.indexrelid
+JOIN pg_class t ON t.oid = x.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN pg_am am ON am.oid = i.relam
+LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = x.indexrelid
+WHERE x.indrelid = 'logs'::regclass
+ORDER BY i.relname`
+
+// Collect builds the report of the logs table.
+func Collect(ctx context.Context, db *sql.DB, cfg Config) (Report, error) {
+	def := DefaultConfig()
+	if cfg.Window <= 0 {
+		cfg.Window = def.Window
+	}
+	if cfg.Top <= 0 {
+		cfg.Top = def.Top
+	}
+
+	now := time.Now().UTC()
+	r := Report{GeneratedAt: now, Since: now.Add(-cfg.Window)}
+
+	var oldest, newest sql.NullTime
+	err := db.QueryRowContext(ctx, overviewSQL).Scan(
+		&r.Rows, &oldest, &newest, &r.EstimatedRows,
+		&r.Size.Table, &r.Size.Indexes, &r.Size.TOAST, &r.Size.Total,
+	)
+	if err != nil {
+		return r, fmt.Errorf("stats: reading table size failed: %w", err)
+	}
+	if oldest.Valid {
+		o, n := oldest.Time.UTC(), newest.Time.UTC()
+		r.Oldest, r.Newest = &o, &n
+	}
+	r.EstimatedRows = max(r.EstimatedRows, 0)
+
+	days := int(math.Ceil(cfg.Window.Hours()/24)) + 1
+	buckets, err := query.New().Since(r.Since).Limit(days).Histogram(ctx, db, 24*time.Hour)
+	if err != nil {
+		return r, fmt.Errorf("stats: %w", err)
+	}
+	r.PerDay = make([]Count, len(buckets))
+	for i, b := range buckets {
+		r.PerDay[i] = Count{Value: b.Start.UTC().Format(time.DateOnly), Count: b.Count}
+	}
+
+	for _, g := range []struct {
+		path  string
+		limit int
+		dst   *[]Count
+	}{
+		{"service", cfg.Top, &r.PerService},
+		{"level", 20, &r.PerLevel},
+		{"msg", cfg.Top, &r.TopMessages},
+	} {
+		groups, err := query.New().Since(r.Since).Limit(g.limit).GroupBy(ctx, db, g.path)
+		if err != nil {
+			return r, fmt.Errorf("stats: %w", err)
+		}
+		*g.dst = make([]Count, len(groups))
+		for i, grp := range groups {
+			(*g.dst)[i] = Count{Value: grp.Value, Null: grp.Null, Count: grp.Count}
+		}
+	}
+
+	if r.Indexes, err = indexes(ctx, db); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// indexes lists the indexes of the table with their bloat estimates.
+func indexes(ctx context.Context, db *sql.DB) ([]Index, error) {
+	rows, err := db.QueryContext(ctx, indexesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("stats: reading indexes failed: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Index{}
+	for rows.Next() {
+		var (
+			idx              Index
+			tuples, pageSize int64
+			plain            bool
+			width            sql.NullInt64
+		)
+		if err := rows.Scan(&idx.Name, &idx.Method, &idx.Bytes, &idx.Scans, &tuples, &plain, &width, &pageSize); err != nil {
+			return nil, fmt.Errorf("stats: scan failed: %w", err)
+		}
+		if idx.Method == "btree" && plain && width.Valid && tuples > 0 {
+			expected := btreeSize(tuples, width.Int64, pageSize)
+			bloat := max(idx.Bytes-expected, 0)
+			ratio := 0.0
+			if idx.Bytes > 0 {
+				ratio = float64(bloat) / float64(idx.Bytes)
+			}
+			idx.BloatBytes, idx.BloatRatio = &bloat, &ratio
+		}
+		out = append(out, idx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("stats: reading indexes failed: %w", err)
+	}
+	return out, nil
+}
+
+// btreeSize estimates the size of a freshly built B-tree index of tuples
+// entries whose keys are width bytes wide on average: leaf pages filled to the
+// default 90% fillfactor, plus the meta page and about 1% of inner pages.
+func btreeSize(tuples, width, pageSize int64) int64 {
+	const (
+		pageHeader  = 24 // PageHeaderData
+		special     = 16 // BTPageOpaqueData
+		tupleHeader = 8  // IndexTupleData
+		linePointer = 4  // ItemIdData
+		maxAlign    = 8
+		fillFactor  = 0.9
+	)
+	tuple := tupleHeader + (width+maxAlign-1)/maxAlign*maxAlign + linePointer
+	perPage := int64(float64(pageSize-pageHeader-special) * fillFactor / float64(tuple))
+	if perPage < 1 {
+		perPage = 1
+	}
+	leaves := (tuples + perPage - 1) / perPage
+	return (1 + leaves + leaves/100) * pageSize
+}
@@ -0,0 +1,254 @@
+// better-logs viewer. Talks to the JSON API served next to this file.
+(() => {
+  "use strict";
+
+  const $ = (sel) => document.querySelector(sel);
+  const cfg = document.body.dataset;
+  const summaries = new Set(["http_request", "grpc_request", "job_run"]);
+
+  const state = {
+    q: "",
+    range: cfg.defaultRange,
+    cursor: null,
+    lastId: null,
+    shown: new Set(), // ids above lastId already rendered in live tail mode
+    timer: null,
+    selected: null,
+  };
+
+  // params returns the filter parameters of the current search.
+  function params(extra = {}) {
+    const p = new URLSearchParams();
+    if (state.q) p.set("q", state.q);
+    p.set("since", state.range);
+    for (const [k, v] of Object.entries(extra)) p.set(k, v);
+    return p;
+  }
+
+  async function api(path, p) {
+    const res = await fetch(`api/${path}?${p}`, { headers: { Accept: "application/json" } });
+    const body = await res.json().catch(() => ({}));
+    if (!res.ok) throw new Error(body.error || res.statusText);
+    return body;
+  }
+
+  function showError(err) {
+    const el = $("#error");
+    el.hidden = !err;
+    el.textContent = err ? String(err.message || err) : "";
+  }
+
+  function el(tag, props = {}, ...children) {
+    const node = Object.assign(document.createElement(tag), props);
+    node.append(...children);
+    return node;
+  }
+
+  function formatTime(entry) {
+    const t = new Date(entry.time && !entry.time.startsWith("0001") ? entry.time : entry.ts);
+    const pad = (n, w = 2) => String(n).padStart(w, "0");
+    return `${t.getFullYear()}-${pad(t.getMonth() + 1)}-${pad(t.getDate())} ` +
+      `${pad(t.getHours())}:${pad(t.getMinutes())}:${pad(t.getSeconds())}.${pad(t.getMilliseconds(), 3)}`;
+  }
+
+  // row builds the table row of an entry.
+  function row(entry) {
+    const req = el("td", { className: "req" });
+    if (entry.req_id) {
+      req.append(el("a", {
+        href: "#",
+        textContent: entry.req_id,
+        onclick: (ev) => { ev.preventDefault(); ev.stopPropagation(); showTrace(entry.req_id); },
+      }));
+    }
+
+    const tr = el("tr", {},
+      el("td", { className: "time", textContent: formatTime(entry) }),
+      el("td", { className: `level-${entry.level}`, textContent: entry.level || "" }),
+      el("td", { textContent: entry.service || "" }),
+      el("td", { textContent: entry.msg || "", title: entry.msg || "" }),
+      req,
+    );
+    if (summaries.has(entry.msg)) tr.classList.add("summary");
+    tr.onclick = () => showDetail(entry, tr);
+    return tr;
+  }
+
+  function renderRows(entries, { append = false, prepend = false } = {}) {
+    const body = $("#results tbody");
+    if (!append && !prepend) body.replaceChildren();
+    const rows = entries.map(row);
+    if (prepend) body.prepend(...rows);
+    else body.append(...rows);
+    $("#empty").hidden = body.children.length > 0;
+  }
+
+  function showDetail(entry, tr) {
+    if (state.selected) state.selected.classList.remove("selected");
+    state.selected = tr;
+    tr.classList.add("selected");
+
+    $("#json").textContent = JSON.stringify(entry.fields, null, 2);
+    const drill = $("#drill");
+    drill.hidden = !entry.req_id && !entry.trace_id;
+    drill.onclick = () => showTrace(entry.req_id, entry.trace_id);
+    $("#detail").hidden = false;
+  }
+
+  function renderFacet(id, key, groups) {
+    const list = $(id);
+    list.replaceChildren(...groups.map((g) => {
+      const value = g.null ? "(none)" : g.value;
+      const li = el("li", {}, el("span", { textContent: value }), el("span", { textContent: g.count }));
+      if (key === "level") li.firstChild.className = `level-${value}`;
+      if (!g.null) li.onclick = () => addTerm(key, g.value);
+      return li;
+    }));
+  }
+
+  function renderHistogram({ buckets, interval_ms: interval }) {
+    const svg = $("#histogram");
+    const width = 1000;
+    const height = 60;
+    svg.setAttribute("viewBox", `0 0 ${width} ${height}`);
+    svg.replaceChildren();
+
+    const end = Date.now();
+    const start = end - parseDuration(state.range);
+    const span = Math.max(end - start, 1);
+    const peak = Math.max(1, ...buckets.map((b) => b.count));
+    const barWidth = Math.max(1, (interval / span) * width - 1);
+
+    for (const b of buckets) {
+      const t = new Date(b.start).getTime();
+      const h = Math.max(1, (b.count / peak) * height);
+      const rect = document.createElementNS("http://www.w3.org/2000/svg", "rect");
+      rect.setAttribute("x", ((t - start) / span) * width);
+      rect.setAttribute("y", height - h);
+      rect.setAttribute("width", barWidth);
+      rect.setAttribute("height", h);
+      const title = document.createElementNS("http://www.w3.org/2000/svg", "title");
+      title.textContent = `${new Date(t).toLocaleString()}: ${b.count}`;
+      rect.append(title);
+      svg.append(rect);
+    }
+  }
+
+  // parseDuration converts the range values ("15m", "1h", "168h") to ms.
+  function parseDuration(s) {
+    const m = /^(\d+)([mh])$/.exec(s);
+    if (!m) return 3600e3;
+    return Number(m[1]) * (m[2] === "h" ? 3600e3 : 60e3);
+  }
+
+  function addTerm(key, value) {
+    const v = /[\s"]/.test(value) ? JSON.stringify(value) : value;
+    state.q = `${state.q} ${key}=${v}`.trim();
+    $("#q").value = state.q;
+    search();
+  }
+
+  async function search() {
+    showError(null);
+    $("#trace-bar").hidden = true;
+    $("#histogram").style.display = "";
+    state.cursor = null;
+    try {
+      const [page, facets, histogram] = await Promise.all([
+        api("logs", params({ limit: 100 })),
+        api("facets", params()),
+        api("histogram", params()),
+      ]);
+      renderRows(page.entries);
+      state.cursor = page.next_cursor || null;
+      $("#more").hidden = !state.cursor;
+      renderFacet("#facet-level", "level", facets.level);
+      renderFacet("#facet-service", "service", facets.service);
+      renderHistogram(histogram);
+    } catch (err) {
+      showError(err);
+    }
+  }
+
+  async function loadMore() {
+    try {
+      const page = await api("logs", params({ limit: 100, cursor: state.cursor }));
+      renderRows(page.entries, { append: true });
+      state.cursor = page.next_cursor || null;
+      $("#more").hidden = !state.cursor;
+    } catch (err) {
+      showError(err);
+    }
+  }
+
+  async function showTrace(reqId, traceId) {
+    stopLive();
+    showError(null);
+    const p = new URLSearchParams();
+    if (reqId) p.set("req_id", reqId);
+    if (traceId) p.set("trace_id", traceId);
+    try {
+      const { entries } = await api("trace", p);
+      renderRows(entries);
+      $("#more").hidden = true;
+      $("#histogram").style.display = "none";
+      $("#trace-title").textContent = `Request ${reqId || traceId}: ${entries.length} lines`;
+      $("#trace-bar").hidden = false;
+    } catch (err) {
+      showError(err);
+    }
+  }
+
+  async function poll() {
+    try {
+      const p = params(state.lastId === null ? {} : { after_id: state.lastId });
+      const { entries, last_id: lastId } = await api("tail", p);
+      // Lines above last_id are returned again until every batch before them
+      // has committed.
+      const fresh = entries.filter((e) => !state.shown.has(e.id));
+      renderRows(fresh, { prepend: state.lastId !== null });
+      for (const e of fresh) state.shown.add(e.id);
+      for (const id of state.shown) if (id <= lastId) state.shown.delete(id);
+      state.lastId = lastId;
+    } catch (err) {
+      showError(err);
+      stopLive();
+    }
+  }
+
+  function startLive() {
+    $("#more").hidden = true;
+    $("#trace-bar").hidden = true;
+    state.lastId = null;
+    state.shown.clear();
+    poll();
+    state.timer = setInterval(poll, Number(cfg.tailInterval) || 2000);
+  }
+
+  function stopLive() {
+    clearInterval(state.timer);
+    state.timer = null;
+    $("#live").checked = false;
+  }
+
+  $("#range").value = state.range;
+  $("#search").onsubmit = (ev) => {
+    ev.preventDefault();
+    state.q = $("#q").value.trim();
+    state.range = $("#range").value;
+    if (state.timer) {
+      stopLive();
+      $("#live").checked = true;
+      startLive();
+    } else {
+      search();
+    }
+  };
+  $("#range").onchange = () => $("#search").requestSubmit();
+  $("#live").onchange = (ev) => (ev.target.checked ? startLive() : (stopLive(), search()));
+  $("#more").onclick = loadMore;
+  $("#back").onclick = search;
+  $("#close").onclick = () => { $("#detail").hidden = true; };
+
+  search();
+})();
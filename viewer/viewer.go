@@ -0,0 +1,275 @@
+// Package viewer serves a web UI to browse the Postgres logs table.
+//
+// The UI is a single page embedded in the binary. It offers a search bar over
+// the query language of the query package, level and service facets, a time
+// histogram, request drill-down, live tail and a JSON detail view.
+//
+// The handler exposes every log line and has no authentication of its own:
+// mount it behind your auth middleware.
+package viewer
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ZiplEix/better-logs/query"
+)
+
+//go:embed static
+var staticFS embed.FS
+
+var indexTmpl = template.Must(template.ParseFS(staticFS, "static/index.html"))
+
+// Config controls the viewer.
+type Config struct {
+	// Title is shown in the page header and title. If empty, "Logs" is used.
+	Title string
+
+	// TailInterval is how often the UI polls for new lines in live tail mode.
+	// If zero or negative, 2s is used.
+	TailInterval time.Duration
+
+	// DefaultRange is the time range selected when the page opens, one of the
+	// ranges offered by the UI ("15m", "1h", "6h", "24h" or "168h").
+	// If empty, "1h" is used.
+	DefaultRange string
+}
+
+// DefaultConfig returns a sane default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Title:        "Logs",
+		TailInterval: 2 * time.Second,
+		DefaultRange: "1h",
+	}
+}
+
+// histogramBuckets is the number of bars drawn for the selected range.
+const histogramBuckets = 60
+
+// tailBatch is the maximum number of lines returned by a tail request.
+const tailBatch = 500
+
+// New returns the viewer handler. It serves the page at "/" and its JSON API
+// under "/api/"; mount it under a prefix with http.StripPrefix, e.g.
+//
+//	mux.Handle("/logs/", http.StripPrefix("/logs", auth(viewer.New(db, viewer.DefaultConfig()))))
+func New(db *sql.DB, cfg Config) http.Handler {
+	def := DefaultConfig()
+	if cfg.Title == "" {
+		cfg.Title = def.Title
+	}
+	if cfg.TailInterval <= 0 {
+		cfg.TailInterval = def.TailInterval
+	}
+	if cfg.DefaultRange == "" {
+		cfg.DefaultRange = def.DefaultRange
+	}
+
+	static, _ := fs.Sub(staticFS, "static")
+	v := &viewer{db: db, cfg: cfg}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", v.index)
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	mux.Handle("GET /api/logs", query.Handler(db))
+	mux.HandleFunc("GET /api/facets", v.facets)
+	mux.HandleFunc("GET /api/histogram", v.histogram)
+	mux.HandleFunc("GET /api/trace", v.trace)
+	mux.HandleFunc("GET /api/tail", v.tail)
+	return mux
+}
+
+// viewer holds the state shared by the handlers.
+type viewer struct {
+	db  *sql.DB
+	cfg Config
+}
+
+// index renders the page.
+func (v *viewer) index(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = indexTmpl.Execute(w, map[string]any{
+		"Title":        v.cfg.Title,
+		"TailInterval": v.cfg.TailInterval.Milliseconds(),
+		"DefaultRange": v.cfg.DefaultRange,
+	})
+}
+
+// facets returns the top levels and services of the matching lines.
+func (v *viewer) facets(w http.ResponseWriter, r *http.Request) {
+	q, ok := parse(w, r.URL.Query())
+	if !ok {
+		return
+	}
+	q.Limit(20)
+
+	levels, err := q.GroupBy(r.Context(), v.db, "level")
+	if err != nil {
+		query.WriteError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	services, err := q.GroupBy(r.Context(), v.db, "service")
+	if err != nil {
+		query.WriteError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, map[string]any{"level": levels, "service": services})
+}
+
+// histogram returns the number of matching lines over time, in about
+// histogramBuckets intervals between since (default 1h ago) and until (default
+// now).
+func (v *viewer) histogram(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	now := time.Now()
+
+	since, until := now.Add(-time.Hour), now
+	var err error
+	if s := params.Get("since"); s != "" {
+		if since, err = query.ParseTime(s, now); err != nil {
+			query.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if s := params.Get("until"); s != "" {
+		if until, err = query.ParseTime(s, now); err != nil {
+			query.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	interval := max(until.Sub(since)/histogramBuckets, time.Second).Round(time.Second)
+
+	params.Set("since", since.Format(time.RFC3339Nano))
+	params.Set("until", until.Format(time.RFC3339Nano))
+	q, ok := parse(w, params)
+	if !ok {
+		return
+	}
+
+	buckets, err := q.Limit(histogramBuckets+1).Histogram(r.Context(), v.db, interval)
+	if err != nil {
+		query.WriteError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, map[string]any{"interval_ms": interval.Milliseconds(), "buckets": buckets})
+}
+
+// trace returns the lines of a request (req_id and/or trace_id parameters).
+func (v *viewer) trace(w http.ResponseWriter, r *http.Request) {
+	reqID, traceID := r.URL.Query().Get("req_id"), r.URL.Query().Get("trace_id")
+	if reqID == "" && traceID == "" {
+		query.WriteError(w, http.StatusBadRequest, "req_id or trace_id is required")
+		return
+	}
+
+	entries, err := query.Trace(r.Context(), v.db, reqID, traceID)
+	if err != nil {
+		query.WriteError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, map[string]any{"entries": entries})
+}
+
+// tail returns the matching lines inserted after the after_id parameter,
+// newest first, and the id to pass on the next call. Without after_id, the
+// latest lines are returned.
+//
+// The returned id is a query.SafeID: lines of batches still being committed
+// are returned by a later call, along with lines already returned, which the
+// client skips by id.
+func (v *viewer) tail(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var after int64 = -1
+	if s := params.Get("after_id"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			query.WriteError(w, http.StatusBadRequest, "after_id must be a positive integer")
+			return
+		}
+		after = n
+	}
+	q, ok := parse(w, params)
+	if !ok {
+		return
+	}
+
+	hi, err := query.MaxID(r.Context(), v.db)
+	if err != nil {
+		query.WriteError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+
+	var (
+		entries []query.LogEntry
+		last    int64
+	)
+	if after < 0 {
+		page, err := q.IDRange(0, hi).Limit(50).Run(r.Context(), v.db)
+		if err != nil {
+			query.WriteError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		last, err = query.FollowStart(r.Context(), v.db, hi, query.DefaultFollowWindow)
+		if err != nil {
+			query.WriteError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		// Older lines that did not fit in the page are not returned later.
+		if page.Next != nil {
+			oldest := hi
+			for _, e := range page.Entries {
+				oldest = min(oldest, e.ID)
+			}
+			last = max(last, oldest-1)
+		}
+		entries = page.Entries
+	} else {
+		last, err = query.SafeID(r.Context(), v.db, after, hi, query.DefaultFollowWindow)
+		if err != nil {
+			query.WriteError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		page, err := q.IDRange(after, hi).Ascending().Limit(tailBatch).Run(r.Context(), v.db)
+		if err != nil {
+			query.WriteError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		if page.Next != nil {
+			last = min(last, page.Next.ID)
+		}
+		entries = page.Entries
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+	if entries == nil {
+		entries = []query.LogEntry{}
+	}
+	writeJSON(w, map[string]any{"entries": entries, "last_id": last})
+}
+
+// parse builds the query of the request parameters, writing a 400 response
+// on error.
+func parse(w http.ResponseWriter, params url.Values) (*query.Query, bool) {
+	q, err := query.FromValues(params, time.Now())
+	if err != nil {
+		query.WriteError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return q, true
+}
+
+// writeJSON writes v as a JSON body.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}